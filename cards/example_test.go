@@ -0,0 +1,50 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cards_test
+
+import (
+	"fmt"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+)
+
+func ExampleMarkdown() {
+	m := &cards.Message{
+		Title:     "GitHub workflow success",
+		Subtitle:  "Workflow: <b>ci</b>",
+		Repo:      "org/repo",
+		Ref:       "refs/heads/main",
+		Actor:     "octocat",
+		Timestamp: "2023-04-25T17:44:57Z",
+		EventName: "workflow",
+		ClickURL:  "https://github.com/org/repo/actions/runs/1",
+		Details:   []cards.Detail{{Label: "Attempt", Value: "2"}},
+	}
+	fmt.Print(cards.Markdown(m))
+	// Output:
+	// ### GitHub workflow success
+	//
+	// Workflow: <b>ci</b>
+	//
+	// | | |
+	// | --- | --- |
+	// | **Repo** | org/repo |
+	// | **Ref** | refs/heads/main |
+	// | **Actor** | octocat |
+	// | **UTC** | 2023-04-25T17:44:57Z |
+	// | **Attempt** | 2 |
+	//
+	// [Open workflow](https://github.com/org/repo/actions/runs/1)
+}
@@ -0,0 +1,93 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cards
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the card as GitHub flavored Markdown, mirroring the
+// widgets of RequestBody. Chat formatting such as <b> and <br> is
+// valid HTML and left as is.
+func Markdown(m *Message) string {
+	var b strings.Builder
+
+	b.WriteString("### ")
+	if m.HeaderIconURL != "" {
+		fmt.Fprintf(&b, `<img src="%s" width="16" height="16"> `, m.HeaderIconURL)
+	}
+	b.WriteString(m.Title + "\n\n")
+	if m.Subtitle != "" {
+		b.WriteString(m.Subtitle + "\n\n")
+	}
+	if m.Text != "" {
+		b.WriteString(m.Text + "\n\n")
+	}
+
+	b.WriteString("| | |\n| --- | --- |\n")
+	row := func(label, value string) {
+		fmt.Fprintf(&b, "| **%s** | %s |\n", markdownTableCell(label), markdownTableCell(value))
+	}
+	row("Repo", m.Repo)
+	row("Ref", m.Ref)
+	row("Actor", m.Actor)
+	row("UTC", m.Timestamp)
+	for _, d := range m.Details {
+		value := d.Value
+		if d.ButtonURL != "" {
+			value = fmt.Sprintf("%s [%s](%s)", value, d.ButtonText, d.ButtonURL)
+		}
+		row(d.Label, value)
+	}
+	b.WriteString("\n")
+
+	if len(m.Chips) > 0 {
+		chips := make([]string, 0, len(m.Chips))
+		for _, c := range m.Chips {
+			chips = append(chips, "`"+c+"`")
+		}
+		b.WriteString(strings.Join(chips, " ") + "\n\n")
+	}
+
+	if m.Grid != nil {
+		if m.Grid.Title != "" {
+			fmt.Fprintf(&b, "**%s**\n\n", m.Grid.Title)
+		}
+		b.WriteString("| | |\n| --- | --- |\n")
+		for _, item := range m.Grid.Items {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownTableCell(item.Label), markdownTableCell(item.Value))
+		}
+		b.WriteString("\n")
+	}
+
+	if m.Excerpt != "" {
+		b.WriteString("> " + m.Excerpt + "\n\n")
+	}
+
+	links := []string{fmt.Sprintf("[Open %s](%s)", m.EventName, m.ClickURL)}
+	for _, l := range m.Links {
+		links = append(links, fmt.Sprintf("[%s](%s)", l.Text, l.URL))
+	}
+	b.WriteString(strings.Join(links, " · ") + "\n\n")
+
+	return b.String()
+}
+
+// markdownTableCell escapes s for use in a Markdown table cell.
+func markdownTableCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
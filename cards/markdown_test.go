@@ -0,0 +1,78 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cards
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCardMarkdown(t *testing.T) {
+	t.Parallel()
+
+	m := &Message{
+		Title:         "GitHub workflow failed",
+		Subtitle:      "Workflow: <b>ci</b>",
+		Ref:           "refs/heads/main",
+		Actor:         "octocat",
+		Timestamp:     "2023-04-25 17:44:57",
+		ClickURL:      "https://github.com/test-org/test-repo/actions/runs/1",
+		HeaderIconURL: FailureHeaderIconURL,
+		EventName:     "workflow",
+		Repo:          "test-org/test-repo",
+		Details: []Detail{
+			{Label: "Attempt", Value: "2"},
+			{Label: "Commit", Value: "a|b", ButtonText: "View", ButtonURL: "https://github.com/test-org/test-repo/commit/a"},
+		},
+		Chips: []string{"bug"},
+		Grid: &Grid{
+			Title: "Inputs",
+			Items: []Detail{{Label: "env", Value: "prod"}},
+		},
+		Excerpt: "Line 1<br>Line 2",
+		Links:   []Link{{Text: "Compare", URL: "https://github.com/test-org/test-repo/compare/v1...v2"}},
+	}
+
+	want := `### <img src="` + FailureHeaderIconURL + `" width="16" height="16"> GitHub workflow failed
+
+Workflow: <b>ci</b>
+
+| | |
+| --- | --- |
+| **Repo** | test-org/test-repo |
+| **Ref** | refs/heads/main |
+| **Actor** | octocat |
+| **UTC** | 2023-04-25 17:44:57 |
+| **Attempt** | 2 |
+| **Commit** | a\|b [View](https://github.com/test-org/test-repo/commit/a) |
+
+` + "`bug`" + `
+
+**Inputs**
+
+| | |
+| --- | --- |
+| env | prod |
+
+> Line 1<br>Line 2
+
+[Open workflow](https://github.com/test-org/test-repo/actions/runs/1) · [Compare](https://github.com/test-org/test-repo/compare/v1...v2)
+
+`
+	if diff := cmp.Diff(want, Markdown(m)); diff != "" {
+		t.Errorf("Markdown got unexpected diff (-want, +got):\n%s", diff)
+	}
+}
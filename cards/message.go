@@ -0,0 +1,231 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cards is the neutral model of a notification and its rendering as a
+// Google Chat card and as GitHub flavored Markdown.
+package cards
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Icons of the card header and of the repo and ref rows.
+const (
+	SuccessHeaderIconURL = "https://github.githubassets.com/favicons/favicon.png"
+	FailureHeaderIconURL = "https://github.githubassets.com/favicons/favicon-failure.png"
+	RunningHeaderIconURL = "https://github.githubassets.com/favicons/favicon-pending.png"
+	WidgetRefIconURL     = "https://fonts.gstatic.com/s/i/short-term/release/googlesymbols/quick_reference/default/48px.svg"
+)
+
+// Message is the content of a notification. It is rendered as a Google Chat
+// card by RequestBody, and by the other backends from the same fields.
+type Message struct {
+	Title         string
+	Subtitle      string
+	Ref           string
+	Actor         string
+	Timestamp     string
+	ClickURL      string
+	HeaderIconURL string
+	EventName     string
+	Repo          string
+	Text          string
+	Details       []Detail
+	Chips         []string
+	Grid          *Grid
+	Excerpt       string
+	Links         []Link
+}
+
+// Grid is a titled key/value grid rendered below the details.
+type Grid struct {
+	Title string
+	Items []Detail
+}
+
+// Link is an extra button rendered next to the main button.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// Detail is an extra labeled row rendered below the common widgets. When
+// ButtonURL is set, the row gets a button opening it.
+type Detail struct {
+	Label      string
+	Value      string
+	ButtonText string
+	ButtonURL  string
+}
+
+// RequestBody returns the Google Chat request body of the message, a cardsV2
+// card and the optional text.
+func RequestBody(m *Message) ([]byte, error) {
+	widgets := []map[string]any{
+		{
+			"decoratedText": map[string]any{
+				"startIcon": map[string]any{
+					"iconUrl": WidgetRefIconURL,
+				},
+				"text": fmt.Sprintf("<b>Repo: </b> %s", m.Repo),
+			},
+		},
+		{
+			"decoratedText": map[string]any{
+				"startIcon": map[string]any{
+					"iconUrl": WidgetRefIconURL,
+				},
+				"text": fmt.Sprintf("<b>Ref: </b> %s", m.Ref),
+			},
+		},
+		{
+			"decoratedText": map[string]any{
+				"startIcon": map[string]any{
+					"knownIcon": "PERSON",
+				},
+				"text": fmt.Sprintf("<b>Actor: </b> %s", m.Actor),
+			},
+		},
+		{
+			"decoratedText": map[string]any{
+				"startIcon": map[string]any{
+					"knownIcon": "CLOCK",
+				},
+				"text": fmt.Sprintf("<b>UTC: </b> %s", m.Timestamp),
+			},
+		},
+	}
+	for _, d := range m.Details {
+		decoratedText := map[string]any{
+			"text": fmt.Sprintf("<b>%s: </b> %s", d.Label, d.Value),
+		}
+		if d.ButtonURL != "" {
+			decoratedText["button"] = map[string]any{
+				"text": d.ButtonText,
+				"onClick": map[string]any{
+					"openLink": map[string]any{
+						"url": d.ButtonURL,
+					},
+				},
+			}
+		}
+		widgets = append(widgets, map[string]any{
+			"decoratedText": decoratedText,
+		})
+	}
+	if len(m.Chips) > 0 {
+		chips := make([]any, 0, len(m.Chips))
+		for _, c := range m.Chips {
+			chips = append(chips, map[string]any{
+				"label": c,
+			})
+		}
+		widgets = append(widgets, map[string]any{
+			"chipList": map[string]any{
+				"chips": chips,
+			},
+		})
+	}
+	if m.Grid != nil {
+		items := make([]any, 0, len(m.Grid.Items))
+		for _, item := range m.Grid.Items {
+			items = append(items, map[string]any{
+				"title":    item.Label,
+				"subtitle": item.Value,
+			})
+		}
+		widgets = append(widgets, map[string]any{
+			"grid": map[string]any{
+				"title":       m.Grid.Title,
+				"columnCount": 2,
+				"items":       items,
+			},
+		})
+	}
+	if m.Excerpt != "" {
+		widgets = append(widgets, map[string]any{
+			"textParagraph": map[string]any{
+				"text": m.Excerpt,
+			},
+		})
+	}
+	buttons := []any{
+		map[string]any{
+			"text": fmt.Sprintf("Open %s", m.EventName),
+			"onClick": map[string]any{
+				"openLink": map[string]any{
+					"url": m.ClickURL,
+				},
+			},
+		},
+	}
+	for _, l := range m.Links {
+		buttons = append(buttons, map[string]any{
+			"text": l.Text,
+			"onClick": map[string]any{
+				"openLink": map[string]any{
+					"url": l.URL,
+				},
+			},
+		})
+	}
+	widgets = append(widgets, map[string]any{
+		"buttonList": map[string]any{
+			"buttons": buttons,
+		},
+	})
+
+	jsonData := map[string]any{
+		"cardsV2": map[string]any{
+			"cardId": "createCardMessage",
+			"card": map[string]any{
+				"header": map[string]any{
+					"title":    m.Title,
+					"subtitle": m.Subtitle,
+					"imageUrl": m.HeaderIconURL,
+				},
+				"sections": []any{
+					map[string]any{
+						"collapsible":               true,
+						"uncollapsibleWidgetsCount": 1,
+						"widgets":                   widgets,
+					},
+				},
+			},
+		},
+	}
+
+	if m.Text != "" {
+		jsonData["text"] = m.Text
+	}
+
+	res, err := json.Marshal(jsonData)
+	if err != nil {
+		return nil, fmt.Errorf("error marshal jsonData: %w", err)
+	}
+	return res, nil
+}
+
+// Fields returns the labeled rows of the message, the common ones
+// followed by the details, as rendered by RequestBody.
+func Fields(m *Message) []Detail {
+	fields := []Detail{
+		{Label: "Repo", Value: m.Repo},
+		{Label: "Ref", Value: m.Ref},
+		{Label: "Actor", Value: m.Actor},
+		{Label: "UTC", Value: m.Timestamp},
+	}
+	return append(fields, m.Details...)
+}
@@ -0,0 +1,120 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cards
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRequestBody(t *testing.T) {
+	t.Parallel()
+
+	m := &Message{
+		EventName: "release",
+		ClickURL:  "https://foo.com",
+		Details: []Detail{
+			{Label: "Author", Value: "test-author"},
+			{Label: "Asset", Value: "tool.tar.gz", ButtonText: "Download", ButtonURL: "https://foo.com/tool.tar.gz"},
+		},
+		Chips: []string{"Draft"},
+		Grid: &Grid{
+			Title: "Inputs",
+			Items: []Detail{
+				{Label: "environment", Value: "staging"},
+			},
+		},
+		Excerpt: "test-excerpt",
+		Links: []Link{
+			{Text: "Download", URL: "https://foo.com/download"},
+		},
+	}
+
+	gotMessageBody, err := RequestBody(m)
+	if err != nil {
+		t.Fatalf("failed to generate messag body %v", err)
+	}
+
+	var got struct {
+		CardsV2 struct {
+			Card struct {
+				Sections []struct {
+					Widgets []map[string]any `json:"widgets"`
+				} `json:"sections"`
+			} `json:"card"`
+		} `json:"cardsV2"`
+	}
+	if err := json.Unmarshal(gotMessageBody, &got); err != nil {
+		t.Fatalf("failed to unmarshal message body: %v", err)
+	}
+
+	// The first four widgets are the repo, ref, actor and timestamp.
+	want := []map[string]any{
+		{
+			"decoratedText": map[string]any{
+				"text": "<b>Author: </b> test-author",
+			},
+		},
+		{
+			"decoratedText": map[string]any{
+				"text": "<b>Asset: </b> tool.tar.gz",
+				"button": map[string]any{
+					"text":    "Download",
+					"onClick": map[string]any{"openLink": map[string]any{"url": "https://foo.com/tool.tar.gz"}},
+				},
+			},
+		},
+		{
+			"chipList": map[string]any{
+				"chips": []any{
+					map[string]any{"label": "Draft"},
+				},
+			},
+		},
+		{
+			"grid": map[string]any{
+				"title":       "Inputs",
+				"columnCount": float64(2),
+				"items": []any{
+					map[string]any{"title": "environment", "subtitle": "staging"},
+				},
+			},
+		},
+		{
+			"textParagraph": map[string]any{
+				"text": "test-excerpt",
+			},
+		},
+		{
+			"buttonList": map[string]any{
+				"buttons": []any{
+					map[string]any{
+						"text":    "Open release",
+						"onClick": map[string]any{"openLink": map[string]any{"url": "https://foo.com"}},
+					},
+					map[string]any{
+						"text":    "Download",
+						"onClick": map[string]any{"openLink": map[string]any{"url": "https://foo.com/download"}},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got.CardsV2.Card.Sections[0].Widgets[4:]); diff != "" {
+		t.Errorf("widgets got unexpected diff (-want, +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chat sends messages to Google Chat, with incoming webhooks or with the
+// Chat REST API, and classifies its error responses.
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+const (
+	DefaultAPIURL = "https://chat.googleapis.com"
+	chatBotScope  = "https://www.googleapis.com/auth/chat.bot"
+)
+
+// Message is the subset of a Chat API message resource that is used here.
+type Message struct {
+	Name   string `json:"name"`
+	Thread struct {
+		Name string `json:"name"`
+	} `json:"thread"`
+}
+
+// Client creates and updates messages with the Chat REST API. Unlike
+// webhooks, it can update a message after it was sent. BaseURL is configurable
+// so tests can point it to a local fake.
+type Client struct {
+	HTTPClient  *http.Client
+	BaseURL     string
+	TokenSource TokenSource
+}
+
+// CreateMessage creates a message in space, e.g. spaces/AAAA, with the given
+// request body using spaces.messages.create. Creating a message again with the
+// same idempotency key returns the existing message.
+func (c *Client) CreateMessage(ctx context.Context, space string, body []byte, idempotencyKey string) (*Message, error) {
+	u, err := WithIdempotencyKey(fmt.Sprintf("%s/v1/%s/messages", strings.TrimSuffix(c.BaseURL, "/"), space), idempotencyKey)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(ctx, http.MethodPost, u, body)
+}
+
+// PatchMessage replaces the text and the cards of the message name, e.g.
+// spaces/AAAA/messages/BBBB, using spaces.messages.patch.
+func (c *Client) PatchMessage(ctx context.Context, name string, body []byte) (*Message, error) {
+	u := fmt.Sprintf("%s/v1/%s?updateMask=text,cardsV2", strings.TrimSuffix(c.BaseURL, "/"), name)
+	return c.do(ctx, http.MethodPatch, u, body)
+}
+
+func (c *Client) do(ctx context.Context, method, u string, body []byte) (*Message, error) {
+	token, err := c.TokenSource.Token(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+
+	var msg Message
+	if err := doJSON(ctx, c.HTTPClient, method, u, "application/json", bytes.NewReader(body),
+		map[string]string{"Authorization": "Bearer " + token}, &msg); err != nil {
+		var herr *HTTPStatusError
+		if errors.As(err, &herr) {
+			return nil, ParseAPIError(herr.StatusCode, herr.Body)
+		}
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// doJSON sends a request and decodes the JSON response into out. The
+// Content-Type header is only set when contentType is not empty.
+func doJSON(ctx context.Context, client *http.Client, method, u, contentType string, body io.Reader, headers map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return fmt.Errorf("creating http request failed: %w", RedactURL(err))
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending http request failed: %w", RedactURL(err))
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		return &HTTPStatusError{StatusCode: got, Body: b}
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google-github-actions/send-google-chat-webhook/internal/chattest"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	var gotBody string
+	srv := chattest.NewAPIServer(t, &gotBody)
+
+	c := &Client{
+		HTTPClient:  srv.Client(),
+		BaseURL:     srv.URL,
+		TokenSource: StaticTokenSource("test-token"),
+	}
+
+	msg, err := c.CreateMessage(context.Background(), "spaces/AAAA", []byte(`{"text":"created"}`), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Name, "spaces/AAAA/messages/BBBB"; got != want {
+		t.Errorf("CreateMessage() got name %q, want %q", got, want)
+	}
+	if got, want := gotBody, `{"text":"created"}`; got != want {
+		t.Errorf("createMessage() sent body %q, want %q", got, want)
+	}
+
+	msg, err = c.PatchMessage(context.Background(), "spaces/AAAA/messages/BBBB", []byte(`{"text":"updated"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Name, "spaces/AAAA/messages/BBBB"; got != want {
+		t.Errorf("PatchMessage() got name %q, want %q", got, want)
+	}
+	if got, want := gotBody, `{"text":"updated"}`; got != want {
+		t.Errorf("patchMessage() sent body %q, want %q", got, want)
+	}
+
+	c.TokenSource = StaticTokenSource("wrong-token")
+	if _, err := c.CreateMessage(context.Background(), "spaces/AAAA", []byte(`{}`), ""); err == nil ||
+		!strings.Contains(err.Error(), "unexpected HTTP status code 401") {
+		t.Errorf("CreateMessage() got error %v, want unauthorized error", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Process exit codes for the classes of Chat errors. Other errors exit with 1.
+const (
+	ExitCodeInvalidMessage = 3
+	ExitCodeInvalidWebhook = 4
+	ExitCodeNotFound       = 5
+	ExitCodeRateLimited    = 6
+	ExitCodeUnavailable    = 7
+)
+
+// URLError is the error of a request to a URL which may hold secrets, e.g. the
+// key and token of a webhook, so only its host is reported.
+type URLError struct {
+	Op   string
+	Host string
+	Err  error
+}
+
+func (e *URLError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Op, e.Host, e.Err)
+}
+
+func (e *URLError) Unwrap() error {
+	return e.Err
+}
+
+// RedactURL returns err with the URL of a *url.Error, e.g. of http.Client.Do,
+// replaced by its host. Other errors are returned as is.
+func RedactURL(err error) error {
+	var uerr *url.Error
+	if !errors.As(err, &uerr) {
+		return err
+	}
+	host := "<redacted>"
+	if u, perr := url.Parse(uerr.URL); perr == nil && u.Host != "" {
+		host = u.Host
+	}
+	return &URLError{Op: uerr.Op, Host: host, Err: uerr.Err}
+}
+
+// HTTPStatusError is an unsuccessful HTTP response that is not decoded further,
+// e.g. of the auth endpoints or of webhooks of other services.
+type HTTPStatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status code %d (%s)\n got body: %s",
+		e.StatusCode, http.StatusText(e.StatusCode), e.Body)
+}
+
+// APIError is an error response of Google Chat, for webhooks and the Chat
+// API alike, decoded from its google.rpc.Status body.
+type APIError struct {
+	// StatusCode is the HTTP status code.
+	StatusCode int
+	// Status is the canonical error code, e.g. INVALID_ARGUMENT. It is empty if
+	// the body is not a google.rpc.Status.
+	Status  string
+	Message string
+	// Reason is the reason of the google.rpc.ErrorInfo detail, e.g.
+	// API_KEY_INVALID, if any.
+	Reason string
+	// FieldPaths are the offending fields of the request, e.g.
+	// cards_v2[0].card.sections[0].widgets[1].
+	FieldPaths []string
+
+	Body []byte
+}
+
+// ParseAPIError decodes the error response body of Google Chat. Bodies
+// that are not a google.rpc.Status are kept as is.
+func ParseAPIError(statusCode int, body []byte) *APIError {
+	e := &APIError{
+		StatusCode: statusCode,
+		Body:       body,
+	}
+
+	var resp struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				Type            string `json:"@type"`
+				Reason          string `json:"reason"`
+				FieldViolations []struct {
+					Field string `json:"field"`
+				} `json:"fieldViolations"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return e
+	}
+
+	e.Status = resp.Error.Status
+	e.Message = resp.Error.Message
+	for _, d := range resp.Error.Details {
+		switch d.Type {
+		case "type.googleapis.com/google.rpc.ErrorInfo":
+			e.Reason = d.Reason
+		case "type.googleapis.com/google.rpc.BadRequest":
+			for _, v := range d.FieldViolations {
+				if v.Field != "" {
+					e.FieldPaths = append(e.FieldPaths, v.Field)
+				}
+			}
+		}
+	}
+	return e
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "unexpected HTTP status code %d (%s)", e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Status == "" && e.Message == "" {
+		fmt.Fprintf(&b, "\n got body: %s", e.Body)
+	} else {
+		fmt.Fprintf(&b, ": %s: %s", e.Status, e.Message)
+	}
+	if e.Reason != "" {
+		fmt.Fprintf(&b, " (reason: %s)", e.Reason)
+	}
+	if len(e.FieldPaths) > 0 {
+		fmt.Fprintf(&b, " (fields: %s)", strings.Join(e.FieldPaths, ", "))
+	}
+	if hint := e.Hint(); hint != "" {
+		fmt.Fprintf(&b, "\n hint: %s", hint)
+	}
+	return b.String()
+}
+
+// Hint returns a likely cause of the error, or an empty string.
+func (e *APIError) Hint() string {
+	switch e.ExitCode() {
+	case ExitCodeInvalidMessage:
+		return "the message was rejected, check the fields of the card"
+	case ExitCodeInvalidWebhook:
+		return "the webhook url or the credentials are invalid or were revoked"
+	case ExitCodeNotFound:
+		return "the space or the message does not exist, it may have been deleted"
+	case ExitCodeRateLimited:
+		return "rate limited by Google Chat, send fewer messages or retry later"
+	case ExitCodeUnavailable:
+		return "Google Chat is unavailable, retry later"
+	default:
+		return ""
+	}
+}
+
+// ExitCode returns the process exit code for the class of the error. Invalid
+// API keys and tokens are reported as INVALID_ARGUMENT as well, so the reason
+// is checked first.
+func (e *APIError) ExitCode() int {
+	switch {
+	case isCredentialsReason(e.Reason):
+		return ExitCodeInvalidWebhook
+	case e.Status == "INVALID_ARGUMENT" || e.StatusCode == http.StatusBadRequest:
+		return ExitCodeInvalidMessage
+	case e.Status == "UNAUTHENTICATED" || e.Status == "PERMISSION_DENIED" ||
+		e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ExitCodeInvalidWebhook
+	case e.Status == "NOT_FOUND" || e.StatusCode == http.StatusNotFound:
+		return ExitCodeNotFound
+	case e.Status == "RESOURCE_EXHAUSTED" || e.StatusCode == http.StatusTooManyRequests:
+		return ExitCodeRateLimited
+	case e.StatusCode >= http.StatusInternalServerError:
+		return ExitCodeUnavailable
+	default:
+		return 1
+	}
+}
+
+// isCredentialsReason reports whether the reason of an error is invalid or
+// missing credentials, e.g. the key of a webhook url.
+func isCredentialsReason(reason string) bool {
+	return strings.HasPrefix(reason, "API_KEY_") || strings.HasPrefix(reason, "ACCESS_TOKEN_") ||
+		reason == "CREDENTIALS_MISSING"
+}
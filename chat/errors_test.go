@@ -0,0 +1,149 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseAPIError(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name           string
+		statusCode     int
+		body           string
+		wantStatus     string
+		wantReason     string
+		wantFieldPaths []string
+		wantExitCode   int
+		wantErr        string
+	}{
+		{
+			name:       "invalid_card",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"code":400,"message":"Invalid JSON payload received.","status":"INVALID_ARGUMENT",` +
+				`"details":[{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[` +
+				`{"field":"cards_v2[0].card.sections[0].widgets[1]","description":"Unknown name"}]}]}}`,
+			wantStatus:     "INVALID_ARGUMENT",
+			wantFieldPaths: []string{"cards_v2[0].card.sections[0].widgets[1]"},
+			wantExitCode:   ExitCodeInvalidMessage,
+			wantErr: "unexpected HTTP status code 400 (Bad Request): INVALID_ARGUMENT: Invalid JSON payload received. " +
+				"(fields: cards_v2[0].card.sections[0].widgets[1])\n hint: the message was rejected",
+		},
+		{
+			name:         "invalid_webhook",
+			statusCode:   http.StatusUnauthorized,
+			body:         `{"error":{"code":401,"message":"Request had invalid authentication credentials.","status":"UNAUTHENTICATED"}}`,
+			wantStatus:   "UNAUTHENTICATED",
+			wantExitCode: ExitCodeInvalidWebhook,
+			wantErr:      "hint: the webhook url or the credentials are invalid",
+		},
+		{
+			name:       "invalid_api_key",
+			statusCode: http.StatusBadRequest,
+			body: `{"error":{"code":400,"message":"API key not valid. Please pass a valid API key.","status":"INVALID_ARGUMENT",` +
+				`"details":[{"@type":"type.googleapis.com/google.rpc.ErrorInfo","reason":"API_KEY_INVALID","domain":"googleapis.com",` +
+				`"metadata":{"service":"chat.googleapis.com"}}]}}`,
+			wantStatus:   "INVALID_ARGUMENT",
+			wantReason:   "API_KEY_INVALID",
+			wantExitCode: ExitCodeInvalidWebhook,
+			wantErr: "INVALID_ARGUMENT: API key not valid. Please pass a valid API key. (reason: API_KEY_INVALID)\n" +
+				" hint: the webhook url or the credentials are invalid",
+		},
+		{
+			name:         "deleted_space",
+			statusCode:   http.StatusNotFound,
+			body:         `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`,
+			wantStatus:   "NOT_FOUND",
+			wantExitCode: ExitCodeNotFound,
+			wantErr:      "hint: the space or the message does not exist",
+		},
+		{
+			name:         "rate_limited",
+			statusCode:   http.StatusTooManyRequests,
+			body:         `{"error":{"code":429,"message":"Resource has been exhausted.","status":"RESOURCE_EXHAUSTED"}}`,
+			wantStatus:   "RESOURCE_EXHAUSTED",
+			wantExitCode: ExitCodeRateLimited,
+			wantErr:      "hint: rate limited",
+		},
+		{
+			name:         "not_a_status",
+			statusCode:   http.StatusBadGateway,
+			body:         `<html>Bad Gateway</html>`,
+			wantExitCode: ExitCodeUnavailable,
+			wantErr:      "unexpected HTTP status code 502 (Bad Gateway)\n got body: <html>Bad Gateway</html>",
+		},
+		{
+			name:         "other",
+			statusCode:   http.StatusConflict,
+			body:         `{"error":{"code":409,"message":"Already exists.","status":"ALREADY_EXISTS"}}`,
+			wantStatus:   "ALREADY_EXISTS",
+			wantExitCode: 1,
+			wantErr:      "ALREADY_EXISTS: Already exists.",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ParseAPIError(tc.statusCode, []byte(tc.body))
+			if got, want := err.Status, tc.wantStatus; got != want {
+				t.Errorf("status got %q, want %q", got, want)
+			}
+			if got, want := err.Reason, tc.wantReason; got != want {
+				t.Errorf("reason got %q, want %q", got, want)
+			}
+			if diff := cmp.Diff(tc.wantFieldPaths, err.FieldPaths); diff != "" {
+				t.Errorf("fieldPaths got unexpected diff (-want, +got):\n%s", diff)
+			}
+			if got, want := err.ExitCode(), tc.wantExitCode; got != want {
+				t.Errorf("ExitCode() got %d, want %d", got, want)
+			}
+			if got := err.Error(); !strings.Contains(got, tc.wantErr) {
+				t.Errorf("Error() got %q, want it to contain %q", got, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSend_APIError(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	_, _, err := Send(context.Background(), srv.Client(), srv.URL, []byte(`{}`))
+
+	var cerr *APIError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("got error %v, want an APIError", err)
+	}
+	if got, want := cerr.ExitCode(), ExitCodeNotFound; got != want {
+		t.Errorf("ExitCode() got %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+func ExampleSend() {
+	// A stand-in for a Google Chat webhook, e.g.
+	// https://chat.googleapis.com/v1/spaces/AAAA/messages?key=...&token=...
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name":"spaces/AAAA/messages/BBBB"}`)
+	}))
+	defer srv.Close()
+
+	// Repeated sends with the same idempotency key create a single message.
+	u, err := chat.WithIdempotencyKey(srv.URL, "run-1-attempt-1")
+	if err != nil {
+		panic(err)
+	}
+
+	statusCode, msg, err := chat.Send(context.Background(), srv.Client(), u, []byte(`{"text":"hello"}`))
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(statusCode, msg.Name)
+	// Output:
+	// 200 spaces/AAAA/messages/BBBB
+}
+
+func ExampleAPIError() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, _, err := chat.Send(context.Background(), srv.Client(), srv.URL, []byte(`{"text":"hello"}`))
+
+	var cerr *chat.APIError
+	if errors.As(err, &cerr) {
+		fmt.Println(cerr.Status, cerr.ExitCode() == chat.ExitCodeNotFound)
+		fmt.Println(cerr.Hint())
+	}
+	// Output:
+	// NOT_FOUND true
+	// the space or the message does not exist, it may have been deleted
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// WithIdempotencyKey returns u with the requestId parameter of
+// spaces.messages.create set to key, so that Chat returns the message of an
+// earlier request with the same key instead of creating another one. A custom
+// messageId is not set, as a repeated one fails with ALREADY_EXISTS. u is
+// returned as is if key is empty.
+func WithIdempotencyKey(u, key string) (string, error) {
+	if key == "" {
+		return u, nil
+	}
+
+	parsed, err := url.Parse(u)
+	if err != nil {
+		// The URL may hold secrets, so it is not part of the error.
+		return "", fmt.Errorf("failed to parse url")
+	}
+	q := parsed.Query()
+	q.Set("requestId", key)
+	parsed.RawQuery = q.Encode()
+	return parsed.String(), nil
+}
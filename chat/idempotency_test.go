@@ -0,0 +1,53 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestWithIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	webhookURL := "https://chat.googleapis.com/v1/spaces/AAAA/messages?key=test-key&token=test-token"
+
+	got, err := WithIdempotencyKey(webhookURL, "test-idempotency-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := parsed.Query()
+	for k, want := range map[string]string{
+		"key":       "test-key",
+		"token":     "test-token",
+		"requestId": "test-idempotency-key",
+		"messageId": "",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("WithIdempotencyKey() got %s=%q, want %q", k, got, want)
+		}
+	}
+	if got, want := parsed.Path, "/v1/spaces/AAAA/messages"; got != want {
+		t.Errorf("WithIdempotencyKey() got path %q, want %q", got, want)
+	}
+
+	if got, err := WithIdempotencyKey(webhookURL, ""); err != nil || got != webhookURL {
+		t.Errorf("WithIdempotencyKey() without a key got %q, %v, want the url as is", got, err)
+	}
+}
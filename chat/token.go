@@ -0,0 +1,141 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// TokenSource returns OAuth 2.0 access tokens for the Chat API.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticTokenSource always returns the same, externally obtained, token.
+type StaticTokenSource string
+
+func (s StaticTokenSource) Token(_ context.Context) (string, error) {
+	return string(s), nil
+}
+
+// serviceAccountKey is the subset of a service account JSON key file that is
+// needed to obtain access tokens.
+type serviceAccountKey struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// ServiceAccountTokenSource exchanges a JWT signed with a service account key
+// for an access token, following the OAuth 2.0 JWT bearer flow.
+type ServiceAccountTokenSource struct {
+	httpClient *http.Client
+	key        *serviceAccountKey
+	signer     *rsa.PrivateKey
+	now        func() time.Time
+}
+
+// NewServiceAccountTokenSource reads the service account key file at pth.
+func NewServiceAccountTokenSource(httpClient *http.Client, pth string) (*ServiceAccountTokenSource, error) {
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file: %w", err)
+	}
+
+	var key serviceAccountKey
+	if err := json.Unmarshal(b, &key); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file: %w", err)
+	}
+	if key.ClientEmail == "" || key.PrivateKey == "" || key.TokenURI == "" {
+		return nil, fmt.Errorf("credentials file is not a service account key")
+	}
+
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("failed to decode the private key of the credentials file")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the private key of the credentials file: %w", err)
+	}
+	signer, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key of the credentials file is not an RSA key")
+	}
+
+	return &ServiceAccountTokenSource{
+		httpClient: httpClient,
+		key:        &key,
+		signer:     signer,
+		now:        time.Now,
+	}, nil
+}
+
+func (s *ServiceAccountTokenSource) Token(ctx context.Context) (string, error) {
+	now := s.now()
+	header, err := json.Marshal(map[string]any{
+		"alg": "RS256",
+		"typ": "JWT",
+		"kid": s.key.PrivateKeyID,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal jwt header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iss":   s.key.ClientEmail,
+		"scope": chatBotScope,
+		"aud":   s.key.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal jwt claims: %w", err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.signer, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign jwt: %w", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", unsigned+"."+base64.RawURLEncoding.EncodeToString(sig))
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(ctx, s.httpClient, http.MethodPost, s.key.TokenURI, "application/x-www-form-urlencoded",
+		strings.NewReader(form.Encode()), nil, &resp); err != nil {
+		return "", fmt.Errorf("failed to exchange the service account jwt: %w", err)
+	}
+	return resp.AccessToken, nil
+}
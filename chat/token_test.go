@@ -0,0 +1,121 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	t.Parallel()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if got, want := r.PostForm.Get("grant_type"), "urn:ietf:params:oauth:grant-type:jwt-bearer"; got != want {
+			http.Error(w, fmt.Sprintf("unexpected grant_type %q", got), http.StatusBadRequest)
+			return
+		}
+
+		parts := strings.Split(r.PostForm.Get("assertion"), ".")
+		if len(parts) != 3 {
+			http.Error(w, "malformed assertion", http.StatusBadRequest)
+			return
+		}
+		sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], sig); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var c struct {
+			Iss   string `json:"iss"`
+			Scope string `json:"scope"`
+		}
+		if err := json.Unmarshal(claims, &c); err != nil || c.Iss != "bot@test-project.iam.gserviceaccount.com" || c.Scope != chatBotScope {
+			http.Error(w, fmt.Sprintf("unexpected claims %s", claims), http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"test-token","token_type":"Bearer","expires_in":3600}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile, err := json.Marshal(&serviceAccountKey{
+		ClientEmail:  "bot@test-project.iam.gserviceaccount.com",
+		PrivateKeyID: "test-key-id",
+		PrivateKey:   string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:     srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pth := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(pth, keyFile, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := NewServiceAccountTokenSource(srv.Client(), pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ts.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "test-token"; got != want {
+		t.Errorf("token() got %q, want %q", got, want)
+	}
+
+	notKey := filepath.Join(t.TempDir(), "not-key.json")
+	if err := os.WriteFile(notKey, []byte(`{"type":"authorized_user"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewServiceAccountTokenSource(srv.Client(), notKey); err == nil ||
+		!strings.Contains(err.Error(), "not a service account key") {
+		t.Errorf("NewServiceAccountTokenSource() got error %v, want not a service account key error", err)
+	}
+}
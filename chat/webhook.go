@@ -0,0 +1,57 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+// Send posts the message body to a single webhook URL. It returns the
+// HTTP status code, if any, and the message created by Chat.
+func Send(ctx context.Context, client *http.Client, url string, body []byte) (int, *Message, error) {
+	request, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("creating http request failed: %w", RedactURL(err))
+	}
+	request.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return 0, nil, fmt.Errorf("sending http request failed: %w", RedactURL(err))
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read")
+	}
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		return got, nil, ParseAPIError(got, bodyBytes)
+	}
+
+	// Webhooks respond with the created message. The message name is only
+	// informative, so a response without it is not an error.
+	var sent Message
+	_ = json.Unmarshal(bodyBytes, &sent)
+	return resp.StatusCode, &sent, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+func TestSend_UserAgent(t *testing.T) {
+	t.Parallel()
+
+	userAgents := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgents <- r.UserAgent()
+		w.Write([]byte(`{"name":"spaces/AAAA/messages/BBBB"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	if _, _, err := Send(context.Background(), srv.Client(), srv.URL, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-userAgents, version.UserAgent(); got != want {
+		t.Errorf("User-Agent got %q, want %q", got, want)
+	}
+}
+
+func TestSend_RedactsURL(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u := srv.URL + "/v1/spaces/AAAA/messages?key=test-key&token=test-token"
+	client := srv.Client()
+	srv.Close()
+
+	_, _, err := Send(context.Background(), client, u, []byte(`{}`))
+	if err == nil {
+		t.Fatal("Send() got no error for a closed server")
+	}
+	for _, secret := range []string{"test-key", "test-token", "AAAA"} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("Send() error %q contains %q of the webhook url", err, secret)
+		}
+	}
+
+	if _, _, err := Send(context.Background(), client, "https://chat.googleapis.com/\x7f?token=test-token", []byte(`{}`)); err == nil || strings.Contains(err.Error(), "test-token") {
+		t.Errorf("Send() got error %v for an invalid url, want one without its token", err)
+	}
+}
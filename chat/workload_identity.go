@@ -0,0 +1,147 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultSTSURL            = "https://sts.googleapis.com"
+	DefaultIAMCredentialsURL = "https://iamcredentials.googleapis.com"
+	cloudPlatformScope       = "https://www.googleapis.com/auth/cloud-platform"
+
+	ActionsIDTokenRequestURLEnvKey   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	ActionsIDTokenRequestTokenEnvKey = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// WorkloadIdentityTokenSource gets access tokens for a service account without
+// a service account key. The GitHub OIDC token of the job is exchanged for a
+// federated token with Google STS, which is then used to impersonate the
+// service account with the IAM credentials API. The job needs the
+// id-token: write permission. The URLs are configurable so tests can point
+// them to local fakes.
+type WorkloadIdentityTokenSource struct {
+	HTTPClient *http.Client
+
+	// OIDCRequestURL and OIDCRequestToken are the values of
+	// ACTIONS_ID_TOKEN_REQUEST_URL and ACTIONS_ID_TOKEN_REQUEST_TOKEN.
+	OIDCRequestURL   string
+	OIDCRequestToken string
+
+	// Provider is the full resource name of the workload identity provider,
+	// e.g. projects/123456789/locations/global/workloadIdentityPools/my-pool/providers/my-provider.
+	Provider       string
+	ServiceAccount string
+
+	STSURL            string
+	IAMCredentialsURL string
+}
+
+func (s *WorkloadIdentityTokenSource) Token(ctx context.Context) (string, error) {
+	if s.OIDCRequestURL == "" || s.OIDCRequestToken == "" {
+		return "", fmt.Errorf("environment vars %s and %s not set, does the job have the id-token: write permission?",
+			ActionsIDTokenRequestURLEnvKey, ActionsIDTokenRequestTokenEnvKey)
+	}
+
+	provider := strings.TrimPrefix(s.Provider, "//iam.googleapis.com/")
+
+	oidcToken, err := s.oidcToken(ctx, "https://iam.googleapis.com/"+provider)
+	if err != nil {
+		return "", fmt.Errorf("failed to get github oidc token: %w", err)
+	}
+	federatedToken, err := s.federatedToken(ctx, "//iam.googleapis.com/"+provider, oidcToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange github oidc token: %w", err)
+	}
+	accessToken, err := s.serviceAccountToken(ctx, federatedToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to impersonate service account %s: %w", s.ServiceAccount, err)
+	}
+	return accessToken, nil
+}
+
+// oidcToken requests a GitHub OIDC token for the job with the given audience.
+func (s *WorkloadIdentityTokenSource) oidcToken(ctx context.Context, audience string) (string, error) {
+	u, err := url.Parse(s.OIDCRequestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", ActionsIDTokenRequestURLEnvKey, err)
+	}
+	q := u.Query()
+	q.Set("audience", audience)
+	u.RawQuery = q.Encode()
+
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := doJSON(ctx, s.HTTPClient, http.MethodGet, u.String(), "", nil,
+		map[string]string{"Authorization": "Bearer " + s.OIDCRequestToken}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+// federatedToken exchanges the OIDC token for a federated access token.
+func (s *WorkloadIdentityTokenSource) federatedToken(ctx context.Context, audience, oidcToken string) (string, error) {
+	body, err := json.Marshal(map[string]string{
+		"grantType":          "urn:ietf:params:oauth:grant-type:token-exchange",
+		"audience":           audience,
+		"scope":              cloudPlatformScope,
+		"requestedTokenType": "urn:ietf:params:oauth:token-type:access_token",
+		"subjectToken":       oidcToken,
+		"subjectTokenType":   "urn:ietf:params:oauth:token-type:jwt",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal token exchange request: %w", err)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	u := strings.TrimSuffix(s.STSURL, "/") + "/v1/token"
+	if err := doJSON(ctx, s.HTTPClient, http.MethodPost, u, "application/json", bytes.NewReader(body), nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.AccessToken, nil
+}
+
+// serviceAccountToken uses the federated token to get an access token of the
+// service account for the Chat API.
+func (s *WorkloadIdentityTokenSource) serviceAccountToken(ctx context.Context, federatedToken string) (string, error) {
+	body, err := json.Marshal(map[string]any{
+		"scope":    []string{chatBotScope},
+		"lifetime": "3600s",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal generate access token request: %w", err)
+	}
+
+	var resp struct {
+		AccessToken string `json:"accessToken"`
+	}
+	u := fmt.Sprintf("%s/v1/projects/-/serviceAccounts/%s:generateAccessToken",
+		strings.TrimSuffix(s.IAMCredentialsURL, "/"), url.PathEscape(s.ServiceAccount))
+	if err := doJSON(ctx, s.HTTPClient, http.MethodPost, u, "application/json", bytes.NewReader(body),
+		map[string]string{"Authorization": "Bearer " + federatedToken}, &resp); err != nil {
+		return "", err
+	}
+	return resp.AccessToken, nil
+}
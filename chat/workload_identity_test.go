@@ -0,0 +1,103 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chat
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google-github-actions/send-google-chat-webhook/internal/chattest"
+)
+
+func TestWorkloadIdentityTokenSource(t *testing.T) {
+	t.Parallel()
+
+	srv := chattest.NewGoogleAuthServer(t)
+
+	cases := []struct {
+		name           string
+		requestToken   string
+		provider       string
+		serviceAccount string
+		want           string
+		wantErr        string
+	}{
+		{
+			name:           "success",
+			requestToken:   "test-request-token",
+			provider:       chattest.WIFProvider,
+			serviceAccount: "chat-bot@test-project.iam.gserviceaccount.com",
+			want:           "test-token",
+		},
+		{
+			name:           "full_provider_name",
+			requestToken:   "test-request-token",
+			provider:       "//iam.googleapis.com/" + chattest.WIFProvider,
+			serviceAccount: "chat-bot@test-project.iam.gserviceaccount.com",
+			want:           "test-token",
+		},
+		{
+			name:           "missing_id_token_permission",
+			provider:       chattest.WIFProvider,
+			serviceAccount: "chat-bot@test-project.iam.gserviceaccount.com",
+			wantErr:        "id-token: write permission",
+		},
+		{
+			name:           "wrong_provider",
+			requestToken:   "test-request-token",
+			provider:       "projects/123/locations/global/workloadIdentityPools/other-pool/providers/test-provider",
+			serviceAccount: "chat-bot@test-project.iam.gserviceaccount.com",
+			wantErr:        "failed to get github oidc token",
+		},
+		{
+			name:           "wrong_service_account",
+			requestToken:   "test-request-token",
+			provider:       chattest.WIFProvider,
+			serviceAccount: "other@test-project.iam.gserviceaccount.com",
+			wantErr:        "failed to impersonate service account other@test-project.iam.gserviceaccount.com",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ts := &WorkloadIdentityTokenSource{
+				HTTPClient:        srv.Client(),
+				OIDCRequestURL:    srv.URL + "/oidc?api-version=2.0",
+				OIDCRequestToken:  tc.requestToken,
+				Provider:          tc.provider,
+				ServiceAccount:    tc.serviceAccount,
+				STSURL:            srv.URL,
+				IAMCredentialsURL: srv.URL,
+			}
+
+			got, err := ts.Token(context.Background())
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("token() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("token() got unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("token() got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
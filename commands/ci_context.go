@@ -0,0 +1,343 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+)
+
+// contextProvider loads the contexts of the run from the environment of a CI
+// system. Other CI systems are mapped to the github and job contexts of GitHub
+// Actions, so that their messages are rendered the same way.
+type contextProvider interface {
+	// name returns the name of the CI system, e.g. for errors.
+	name() string
+
+	// detect returns true if the command runs on the CI system.
+	detect(getenv func(string) string) bool
+
+	// load returns the github and job contexts of the run.
+	load(getenv func(string) string) (ghJSON, jobJSON map[string]any, err error)
+
+	// requiresStatus returns true if the CI system doesn't always expose the
+	// final status of the job to the run, so that it must then be passed with
+	// --job-status.
+	requiresStatus() bool
+}
+
+// contextProviders are the providers of CI systems other than GitHub Actions,
+// in the order they are detected. Jenkins comes before Cloud Build as both
+// set BUILD_ID.
+var contextProviders = []contextProvider{
+	gitlabContextProvider{},
+	jenkinsContextProvider{},
+	cloudBuildContextProvider{},
+}
+
+// loadContexts returns the github and job contexts of the run. GitHub Actions
+// is used when any of its contexts is available, and otherwise the first
+// detected CI system. When none is detected, the errors are the ones of GitHub
+// Actions. A non-empty status replaces the status of the job, and is required
+// for CI systems not exposing it to the run, as the card would otherwise read
+// as a failure.
+func loadContexts(contextFile, status string, getenv func(string) string) (map[string]any, map[string]any, error) {
+	var p contextProvider = &githubContextProvider{contextFile: contextFile}
+	if !p.detect(getenv) {
+		for _, cp := range contextProviders {
+			if cp.detect(getenv) {
+				p = cp
+				break
+			}
+		}
+	}
+
+	ghJSON, jobJSON, err := p.load(getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	if status != "" {
+		jobJSON["status"] = normalizeJobStatus(status)
+	}
+	if p.requiresStatus() && githubevents.StringValue(jobJSON, "status") == "" {
+		return nil, nil, fmt.Errorf("the job status is not available on %s, set it with --job-status", p.name())
+	}
+	return ghJSON, jobJSON, nil
+}
+
+// normalizeJobStatus maps the statuses of other CI systems to the ones of GitHub
+// Actions jobs.
+func normalizeJobStatus(status string) string {
+	switch s := strings.ToLower(strings.TrimSpace(status)); s {
+	case "failed", "fail", "unstable", "timeout", "internal_error", "expired":
+		return "failure"
+	case "canceled", "aborted":
+		return "cancelled"
+	case "succeeded", "passed":
+		return "success"
+	default:
+		return s
+	}
+}
+
+// githubContextProvider loads the contexts of GitHub Actions.
+type githubContextProvider struct {
+	contextFile string
+}
+
+func (*githubContextProvider) name() string {
+	return "GitHub Actions"
+}
+
+func (p *githubContextProvider) detect(getenv func(string) string) bool {
+	return p.contextFile != "" || getenv("GITHUB_ACTIONS") == "true" ||
+		getenv(githubevents.ContextEnvKey) != "" || getenv(githubevents.EventPathEnvKey) != "" ||
+		getenv(jobContextEnvKey) != ""
+}
+
+func (*githubContextProvider) requiresStatus() bool {
+	return false
+}
+
+func (p *githubContextProvider) load(getenv func(string) string) (map[string]any, map[string]any, error) {
+	ghJSON, err := githubevents.LoadContext(p.contextFile, getenv)
+	if err != nil {
+		return nil, nil, err
+	}
+	jobJSONStr := getenv(jobContextEnvKey)
+	if jobJSONStr == "" {
+		return nil, nil, fmt.Errorf("environment var %s not set", jobContextEnvKey)
+	}
+
+	jobJSON := map[string]any{}
+	if err := json.Unmarshal([]byte(jobJSONStr), &jobJSON); err != nil {
+		return nil, nil, fmt.Errorf("failed unmarshaling %s: %w", jobContextEnvKey, err)
+	}
+	return ghJSON, jobJSON, nil
+}
+
+// gitlabContextProvider loads the contexts of GitLab CI from its predefined
+// variables.
+// https://docs.gitlab.com/ee/ci/variables/predefined_variables.html
+type gitlabContextProvider struct{}
+
+func (gitlabContextProvider) name() string {
+	return "GitLab CI"
+}
+
+func (gitlabContextProvider) detect(getenv func(string) string) bool {
+	return getenv("GITLAB_CI") == "true"
+}
+
+func (gitlabContextProvider) requiresStatus() bool {
+	return true
+}
+
+func (gitlabContextProvider) load(getenv func(string) string) (map[string]any, map[string]any, error) {
+	eventName := getenv("CI_PIPELINE_SOURCE")
+	switch eventName {
+	case "merge_request_event":
+		eventName = "pull_request"
+	case "web":
+		eventName = "workflow_dispatch"
+	}
+
+	workflow := getenv("CI_PIPELINE_NAME")
+	if workflow == "" {
+		workflow = getenv("CI_PROJECT_NAME")
+	}
+
+	branch := getenv("CI_COMMIT_BRANCH")
+	if branch == "" {
+		branch = getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME")
+	}
+
+	ghJSON := ciGitHubContext(map[string]string{
+		githubevents.RepositoryKey:      getenv("CI_PROJECT_PATH"),
+		githubevents.ServerURLKey:       getenv("CI_SERVER_URL"),
+		githubevents.RefKey:             ciRef(branch, getenv("CI_COMMIT_TAG")),
+		githubevents.TriggeringActorKey: getenv("GITLAB_USER_LOGIN"),
+		githubevents.EventNameKey:       eventName,
+		githubevents.RunURLKey:          getenv("CI_PIPELINE_URL"),
+		"actor":                         getenv("GITLAB_USER_LOGIN"),
+		"run_id":                        getenv("CI_PIPELINE_ID"),
+		"sha":                           getenv("CI_COMMIT_SHA"),
+		"job":                           getenv("CI_JOB_NAME"),
+		"workflow":                      workflow,
+	})
+	// CI_JOB_STATUS is running until after_script, which is the only place it
+	// holds the status the job ended with.
+	jobJSON := map[string]any{}
+	switch status := getenv("CI_JOB_STATUS"); status {
+	case "success", "failed", "canceled":
+		jobJSON["status"] = normalizeJobStatus(status)
+	}
+	return ghJSON, jobJSON, nil
+}
+
+// cloudBuildContextProvider loads the contexts of Cloud Build from its default
+// substitutions, which are available to build steps as environment variables
+// with the automapSubstitutions option.
+// https://cloud.google.com/build/docs/configuring-builds/substitute-variable-values
+type cloudBuildContextProvider struct{}
+
+func (cloudBuildContextProvider) name() string {
+	return "Cloud Build"
+}
+
+func (cloudBuildContextProvider) detect(getenv func(string) string) bool {
+	return getenv("BUILD_ID") != "" && getenv("PROJECT_ID") != ""
+}
+
+func (cloudBuildContextProvider) requiresStatus() bool {
+	return true
+}
+
+func (cloudBuildContextProvider) load(getenv func(string) string) (map[string]any, map[string]any, error) {
+	repo := getenv("REPO_FULL_NAME")
+	if repo == "" {
+		repo = getenv("REPO_NAME")
+	}
+	location := getenv("LOCATION")
+	if location == "" {
+		location = "global"
+	}
+	workflow := getenv("TRIGGER_NAME")
+	if workflow == "" {
+		workflow = "Cloud Build"
+	}
+
+	eventName := "workflow_dispatch"
+	switch {
+	case getenv("_PR_NUMBER") != "":
+		eventName = "pull_request"
+	case getenv("TRIGGER_NAME") != "":
+		eventName = "push"
+	}
+
+	ghJSON := ciGitHubContext(map[string]string{
+		githubevents.RepositoryKey: repo,
+		githubevents.RefKey:        ciRef(getenv("BRANCH_NAME"), getenv("TAG_NAME")),
+		githubevents.EventNameKey:  eventName,
+		githubevents.RunURLKey: fmt.Sprintf("https://console.cloud.google.com/cloud-build/builds;region=%s/%s?project=%s",
+			location, url.PathEscape(getenv("BUILD_ID")), url.QueryEscape(getenv("PROJECT_ID"))),
+		"run_id":   getenv("BUILD_ID"),
+		"sha":      getenv("COMMIT_SHA"),
+		"workflow": workflow,
+	})
+	// The status of the build is not available to its steps.
+	return ghJSON, map[string]any{}, nil
+}
+
+// jenkinsContextProvider loads the contexts of Jenkins from its environment
+// variables, including the ones of the Git and multibranch pipeline plugins.
+// https://www.jenkins.io/doc/book/pipeline/jenkinsfile/#using-environment-variables
+type jenkinsContextProvider struct{}
+
+func (jenkinsContextProvider) name() string {
+	return "Jenkins"
+}
+
+func (jenkinsContextProvider) detect(getenv func(string) string) bool {
+	return getenv("JENKINS_URL") != "" && getenv("JOB_NAME") != ""
+}
+
+func (jenkinsContextProvider) requiresStatus() bool {
+	return true
+}
+
+func (jenkinsContextProvider) load(getenv func(string) string) (map[string]any, map[string]any, error) {
+	repo := jenkinsRepository(getenv("GIT_URL"))
+	if repo == "" {
+		repo = getenv("JOB_NAME")
+	}
+
+	branch := getenv("BRANCH_NAME")
+	if branch == "" {
+		branch = strings.TrimPrefix(getenv("GIT_BRANCH"), "origin/")
+	}
+
+	actor := getenv("BUILD_USER_ID")
+	if actor == "" {
+		actor = getenv("CHANGE_AUTHOR")
+	}
+
+	eventName := "push"
+	if getenv("CHANGE_ID") != "" {
+		eventName = "pull_request"
+	}
+
+	ghJSON := ciGitHubContext(map[string]string{
+		githubevents.RepositoryKey:      repo,
+		githubevents.ServerURLKey:       strings.TrimSuffix(getenv("JENKINS_URL"), "/"),
+		githubevents.RefKey:             ciRef(branch, getenv("TAG_NAME")),
+		githubevents.TriggeringActorKey: actor,
+		githubevents.EventNameKey:       eventName,
+		githubevents.RunURLKey:          getenv("BUILD_URL"),
+		"actor":                         actor,
+		"run_id":                        getenv("BUILD_NUMBER"),
+		"sha":                           getenv("GIT_COMMIT"),
+		"job":                           getenv("STAGE_NAME"),
+		"workflow":                      getenv("JOB_NAME"),
+	})
+	// The result of the build is only available to the pipeline, e.g. as
+	// currentBuild.currentResult, and is passed with --job-status.
+	return ghJSON, map[string]any{}, nil
+}
+
+// jenkinsRepository returns the owner/name path of the git remote u, empty if
+// it can't be parsed.
+func jenkinsRepository(u string) string {
+	if u == "" {
+		return ""
+	}
+	var pth string
+	if parsed, err := url.Parse(u); err == nil && parsed.Host != "" {
+		pth = parsed.Path
+	} else if _, p, ok := strings.Cut(u, ":"); ok {
+		// scp-like syntax, e.g. git@github.com:owner/name.git.
+		pth = p
+	}
+	return strings.TrimSuffix(strings.Trim(pth, "/"), ".git")
+}
+
+// ciRef returns the fully qualified ref of a tag, or else of a branch.
+func ciRef(branch, tag string) string {
+	switch {
+	case tag != "":
+		return "refs/tags/" + tag
+	case branch != "":
+		return "refs/heads/" + branch
+	default:
+		return ""
+	}
+}
+
+// ciGitHubContext returns the github context holding the non-empty values.
+func ciGitHubContext(values map[string]string) map[string]any {
+	ghJSON := map[string]any{
+		githubevents.EventKey: map[string]any{},
+	}
+	for k, v := range values {
+		if v != "" {
+			ghJSON[k] = v
+		}
+	}
+	return ghJSON
+}
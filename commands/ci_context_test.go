@@ -0,0 +1,268 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadContexts(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		env        map[string]string
+		status     string
+		wantGitHub map[string]any
+		wantJob    map[string]any
+		wantRunURL string
+		expErr     string
+	}{
+		{
+			name: "github",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"org/repo","run_id":"1"}`,
+				"JOB_CONTEXT":    `{"status":"success"}`,
+			},
+			wantGitHub: map[string]any{"repository": "org/repo", "run_id": "1"},
+			wantJob:    map[string]any{"status": "success"},
+			wantRunURL: "https://github.com/org/repo/actions/runs/1",
+		},
+		{
+			name: "github_status",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"org/repo"}`,
+				"JOB_CONTEXT":    `{"status":"success"}`,
+			},
+			status:     "FAILED",
+			wantGitHub: map[string]any{"repository": "org/repo"},
+			wantJob:    map[string]any{"status": "failure"},
+			wantRunURL: "https://github.com/org/repo/actions/runs/",
+		},
+		{
+			name: "github_without_status",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"org/repo","run_id":"1"}`,
+				"JOB_CONTEXT":    `{}`,
+			},
+			wantGitHub: map[string]any{"repository": "org/repo", "run_id": "1"},
+			wantJob:    map[string]any{},
+			wantRunURL: "https://github.com/org/repo/actions/runs/1",
+		},
+		{
+			name: "gitlab",
+			env: map[string]string{
+				"GITLAB_CI":          "true",
+				"CI_PROJECT_PATH":    "group/project",
+				"CI_PROJECT_NAME":    "project",
+				"CI_SERVER_URL":      "https://gitlab.com",
+				"CI_COMMIT_BRANCH":   "main",
+				"CI_PIPELINE_SOURCE": "push",
+				"CI_PIPELINE_ID":     "42",
+				"CI_PIPELINE_URL":    "https://gitlab.com/group/project/-/pipelines/42",
+				"CI_COMMIT_SHA":      "abc",
+				"CI_JOB_NAME":        "test",
+				"CI_JOB_STATUS":      "failed",
+				"GITLAB_USER_LOGIN":  "octocat",
+			},
+			wantGitHub: map[string]any{
+				"event":            map[string]any{},
+				"repository":       "group/project",
+				"server_url":       "https://gitlab.com",
+				"ref":              "refs/heads/main",
+				"triggering_actor": "octocat",
+				"actor":            "octocat",
+				"event_name":       "push",
+				"run_url":          "https://gitlab.com/group/project/-/pipelines/42",
+				"run_id":           "42",
+				"sha":              "abc",
+				"job":              "test",
+				"workflow":         "project",
+			},
+			wantJob:    map[string]any{"status": "failure"},
+			wantRunURL: "https://gitlab.com/group/project/-/pipelines/42",
+		},
+		{
+			name: "gitlab_merge_request_tag",
+			env: map[string]string{
+				"GITLAB_CI":          "true",
+				"CI_COMMIT_TAG":      "v1.0.0",
+				"CI_PIPELINE_SOURCE": "merge_request_event",
+				"CI_PIPELINE_NAME":   "release",
+				"CI_JOB_STATUS":      "canceled",
+			},
+			wantGitHub: map[string]any{
+				"event":      map[string]any{},
+				"ref":        "refs/tags/v1.0.0",
+				"event_name": "pull_request",
+				"workflow":   "release",
+			},
+			wantJob:    map[string]any{"status": "cancelled"},
+			wantRunURL: "https://github.com//actions/runs/",
+		},
+		{
+			name: "cloud_build",
+			env: map[string]string{
+				"BUILD_ID":       "b-1",
+				"PROJECT_ID":     "my-project",
+				"LOCATION":       "us-central1",
+				"REPO_FULL_NAME": "org/repo",
+				"BRANCH_NAME":    "main",
+				"COMMIT_SHA":     "abc",
+				"TRIGGER_NAME":   "deploy",
+			},
+			status: "SUCCESS",
+			wantGitHub: map[string]any{
+				"event":      map[string]any{},
+				"repository": "org/repo",
+				"ref":        "refs/heads/main",
+				"event_name": "push",
+				"run_url":    "https://console.cloud.google.com/cloud-build/builds;region=us-central1/b-1?project=my-project",
+				"run_id":     "b-1",
+				"sha":        "abc",
+				"workflow":   "deploy",
+			},
+			wantJob:    map[string]any{"status": "success"},
+			wantRunURL: "https://console.cloud.google.com/cloud-build/builds;region=us-central1/b-1?project=my-project",
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL":  "https://jenkins.example.com/",
+				"JOB_NAME":     "org/repo/main",
+				"BUILD_ID":     "7",
+				"BUILD_NUMBER": "7",
+				"BUILD_URL":    "https://jenkins.example.com/job/org/job/repo/job/main/7/",
+				"GIT_URL":      "git@github.com:org/repo.git",
+				"GIT_BRANCH":   "origin/main",
+				"GIT_COMMIT":   "abc",
+				"STAGE_NAME":   "Notify",
+			},
+			status: "ABORTED",
+			wantGitHub: map[string]any{
+				"event":      map[string]any{},
+				"repository": "org/repo",
+				"server_url": "https://jenkins.example.com",
+				"ref":        "refs/heads/main",
+				"event_name": "push",
+				"run_url":    "https://jenkins.example.com/job/org/job/repo/job/main/7/",
+				"run_id":     "7",
+				"sha":        "abc",
+				"job":        "Notify",
+				"workflow":   "org/repo/main",
+			},
+			wantJob:    map[string]any{"status": "cancelled"},
+			wantRunURL: "https://jenkins.example.com/job/org/job/repo/job/main/7/",
+		},
+		{
+			name: "gitlab_running_status",
+			env: map[string]string{
+				"GITLAB_CI":       "true",
+				"CI_PROJECT_PATH": "org/repo",
+				"CI_JOB_STATUS":   "running",
+			},
+			status: "failed",
+			wantGitHub: map[string]any{
+				"event":      map[string]any{},
+				"repository": "org/repo",
+			},
+			wantJob:    map[string]any{"status": "failure"},
+			wantRunURL: "https://github.com/org/repo/actions/runs/",
+		},
+		{
+			name: "gitlab_running_without_status",
+			env: map[string]string{
+				"GITLAB_CI":     "true",
+				"CI_JOB_STATUS": "running",
+			},
+			expErr: "the job status is not available on GitLab CI, set it with --job-status",
+		},
+		{
+			name: "cloud_build_without_status",
+			env: map[string]string{
+				"BUILD_ID":   "b-1",
+				"PROJECT_ID": "my-project",
+			},
+			expErr: "the job status is not available on Cloud Build, set it with --job-status",
+		},
+		{
+			name: "jenkins_without_status",
+			env: map[string]string{
+				"JENKINS_URL": "https://jenkins.example.com/",
+				"JOB_NAME":    "org/repo/main",
+			},
+			expErr: "the job status is not available on Jenkins, set it with --job-status",
+		},
+		{
+			name:   "none",
+			env:    map[string]string{},
+			expErr: "environment vars GITHUB_CONTEXT and GITHUB_EVENT_PATH not set",
+		},
+		{
+			name: "github_missing_job_context",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{}`,
+			},
+			expErr: "environment var JOB_CONTEXT not set",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ghJSON, jobJSON, err := loadContexts("", tc.status, func(k string) string { return tc.env[k] })
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tc.wantGitHub, ghJSON); diff != "" {
+				t.Errorf("github context got unexpected diff (-want, +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantJob, jobJSON); diff != "" {
+				t.Errorf("job context got unexpected diff (-want, +got):\n%s", diff)
+			}
+			if got, want := githubevents.WorkflowRunURL(ghJSON), tc.wantRunURL; got != want {
+				t.Errorf("githubevents.WorkflowRunURL() got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestJenkinsRepository(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		url  string
+		want string
+	}{
+		{url: "https://github.com/org/repo.git", want: "org/repo"},
+		{url: "git@github.com:org/repo.git", want: "org/repo"},
+		{url: "ssh://git@gitlab.example.com:2222/group/sub/repo", want: "group/sub/repo"},
+		{url: "", want: ""},
+	}
+
+	for _, tc := range cases {
+		if got := jenkinsRepository(tc.url); got != tc.want {
+			t.Errorf("jenkinsRepository(%q) got %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// Status transitions reported by statusChange.
+const (
+	statusChangeFirst     = "first"
+	statusChangeBroken    = "broken"
+	statusChangeFixed     = "fixed"
+	statusChangeUnchanged = "unchanged"
+)
+
+// githubClient is a minimal client for the GitHub REST API. baseURL is
+// configurable so tests can point it to a local fake.
+type githubClient struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// workflowRun is the subset of a workflow run returned by the GitHub API that
+// is used here.
+type workflowRun struct {
+	ID         int64  `json:"id"`
+	Conclusion string `json:"conclusion"`
+}
+
+// inconclusiveConclusions are the conclusions of runs that neither succeeded
+// nor failed, which don't tell whether the workflow was broken before.
+var inconclusiveConclusions = []string{"skipped", "neutral", "action_required"}
+
+// previousConclusion returns the conclusion of the latest completed run of the
+// workflow on branch, or on any ref if branch is empty, ignoring the run with
+// currentRunID and runs with inconclusiveConclusions. It returns an empty
+// string if there is no such run.
+func (g *githubClient) previousConclusion(ctx context.Context, repo, workflowFile, branch, currentRunID string) (string, error) {
+	if workflowFile == "" {
+		return "", fmt.Errorf("unable to determine the workflow file from workflow_ref")
+	}
+
+	q := url.Values{}
+	if branch != "" {
+		q.Set("branch", branch)
+	}
+	q.Set("status", "completed")
+	q.Set("per_page", "10")
+
+	var body struct {
+		WorkflowRuns []*workflowRun `json:"workflow_runs"`
+	}
+	if err := g.list(ctx, fmt.Sprintf("/repos/%s/actions/workflows/%s/runs", repo, url.PathEscape(workflowFile)), q, "workflow runs", &body); err != nil {
+		return "", err
+	}
+
+	currentID, _ := strconv.ParseInt(currentRunID, 10, 64)
+	for _, run := range body.WorkflowRuns {
+		if run.ID != currentID && !slices.Contains(inconclusiveConclusions, run.Conclusion) {
+			return run.Conclusion, nil
+		}
+	}
+	return "", nil
+}
+
+// previousTag returns the tag of repo with the highest version below the one
+// of tag, among the latest 100 tags. Pre-releases are only considered for
+// pre-release tags. It returns an empty string if tag is not a version or there
+// is no such tag.
+func (g *githubClient) previousTag(ctx context.Context, repo, tag string) (string, error) {
+	current, ok := parseTagVersion(tag)
+	if !ok {
+		return "", nil
+	}
+
+	q := url.Values{}
+	q.Set("per_page", "100")
+
+	var tags []struct {
+		Name string `json:"name"`
+	}
+	if err := g.list(ctx, fmt.Sprintf("/repos/%s/tags", repo), q, "tags", &tags); err != nil {
+		return "", err
+	}
+
+	var previous string
+	var previousVersion *tagVersion
+	for _, t := range tags {
+		v, ok := parseTagVersion(t.Name)
+		if !ok || (v.pre != "" && current.pre == "") || v.compare(current) >= 0 {
+			continue
+		}
+		if previousVersion == nil || v.compare(previousVersion) > 0 {
+			previous, previousVersion = t.Name, v
+		}
+	}
+	return previous, nil
+}
+
+// list decodes the response of the GET request to the API path with the query
+// q into out. what names the listed resources in errors.
+func (g *githubClient) list(ctx context.Context, pth string, q url.Values, what string, out any) error {
+	u := fmt.Sprintf("%s%s?%s", strings.TrimSuffix(g.baseURL, "/"), pth, q.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return fmt.Errorf("creating http request failed: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", version.UserAgent())
+	if g.token != "" {
+		req.Header.Set("Authorization", "Bearer "+g.token)
+	}
+
+	resp, err := g.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("sending http request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if got, want := resp.StatusCode, http.StatusOK; got != want {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("unexpected HTTP status code %d (%s) listing %s\n got body: %s", got, http.StatusText(got), what, b)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", what, err)
+	}
+	return nil
+}
+
+// tagVersion is the version of a tag, e.g. v1.2.3 or 1.2.3-rc.1.
+type tagVersion struct {
+	nums []int
+	pre  string
+}
+
+// parseTagVersion returns the version of tag, and false if it is not a
+// version.
+func parseTagVersion(tag string) (*tagVersion, bool) {
+	s, _, _ := strings.Cut(strings.TrimPrefix(tag, "v"), "+")
+	s, pre, _ := strings.Cut(s, "-")
+
+	parts := strings.Split(s, ".")
+	nums := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		nums = append(nums, n)
+	}
+	return &tagVersion{nums: nums, pre: pre}, true
+}
+
+// compare returns -1, 0 or 1 if v is lower than, equal to or higher than o. A
+// pre-release is lower than the release of the same version.
+func (v *tagVersion) compare(o *tagVersion) int {
+	if c := slices.Compare(v.nums, o.nums); c != 0 {
+		return c
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	default:
+		return strings.Compare(v.pre, o.pre)
+	}
+}
+
+// statusChange compares the job status of the current run with the conclusion
+// of the previous run. It returns statusChangeFirst when there is no previous
+// run, and statusChangeUnchanged when both succeeded or both failed.
+func statusChange(previousConclusion, currentStatus string) string {
+	if previousConclusion == "" {
+		return statusChangeFirst
+	}
+
+	previousFailed, currentFailed := githubevents.IsFailedStatus(previousConclusion), githubevents.IsFailedStatus(currentStatus)
+	switch {
+	case !previousFailed && currentFailed:
+		return statusChangeBroken
+	case previousFailed && !currentFailed:
+		return statusChangeFixed
+	default:
+		return statusChangeUnchanged
+	}
+}
+
+// workflowFileAndBranch returns the file name of the running workflow and the
+// branch it runs on, as needed to look up previous runs. For pull requests the
+// branch is the head branch. For tags it is empty, as every tag is a new ref,
+// so that the previous run is the one on any ref.
+func workflowFileAndBranch(ghJSON map[string]any) (string, string) {
+	// workflow_ref looks like
+	// octo-org/octo-repo/.github/workflows/ci.yml@refs/heads/main.
+	workflowRef, _, _ := strings.Cut(githubevents.StringValue(ghJSON, "workflow_ref"), "@")
+	workflowFile := ""
+	if workflowRef != "" {
+		workflowFile = path.Base(workflowRef)
+	}
+
+	branch := githubevents.StringValue(ghJSON, "head_ref")
+	if ref := githubevents.StringValue(ghJSON, githubevents.RefKey); branch == "" && !strings.HasPrefix(ref, "refs/tags/") {
+		branch = strings.TrimPrefix(ref, "refs/heads/")
+	}
+	return workflowFile, branch
+}
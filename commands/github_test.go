@@ -0,0 +1,289 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newFakeGitHub returns a fake GitHub API listing the given workflow runs for
+// the ci.yml workflow on the main branch, and the tags of the repository.
+func newFakeGitHub(t *testing.T, runs string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.Header.Get("Authorization"), "Bearer test-token"; got != want {
+			http.Error(w, fmt.Sprintf("unexpected authorization %q", got), http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/repos/test-org/test-repo/tags" {
+			fmt.Fprint(w, `[{"name":"v1.3.0-rc.1"},{"name":"v1.10.0"},{"name":"v1.2.0"},{"name":"latest"},{"name":"v1.1.0"}]`)
+			return
+		}
+		if got, want := r.URL.Path, "/repos/test-org/test-repo/actions/workflows/ci.yml/runs"; got != want {
+			http.Error(w, fmt.Sprintf("unexpected path %q", got), http.StatusNotFound)
+			return
+		}
+		if got := r.URL.Query().Get("branch"); got != "" && got != "main" {
+			fmt.Fprint(w, `{"workflow_runs":[]}`)
+			return
+		}
+		fmt.Fprintf(w, `{"workflow_runs":%s}`, runs)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGitHubClient_PreviousConclusion(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeGitHub(t, `[{"id":4,"conclusion":"skipped"},{"id":3,"conclusion":"neutral"},{"id":2,"conclusion":"failure"},{"id":1,"conclusion":"success"}]`)
+
+	cases := []struct {
+		name         string
+		workflowFile string
+		branch       string
+		currentRunID string
+		token        string
+		want         string
+		wantErr      bool
+	}{
+		{
+			name:         "latest_run",
+			workflowFile: "ci.yml",
+			branch:       "main",
+			currentRunID: "5",
+			token:        "test-token",
+			want:         "failure",
+		},
+		{
+			name:         "skips_inconclusive_runs",
+			workflowFile: "ci.yml",
+			branch:       "main",
+			currentRunID: "4",
+			token:        "test-token",
+			want:         "failure",
+		},
+		{
+			name:         "skips_current_run",
+			workflowFile: "ci.yml",
+			branch:       "main",
+			currentRunID: "2",
+			token:        "test-token",
+			want:         "success",
+		},
+		{
+			name:         "no_runs",
+			workflowFile: "ci.yml",
+			branch:       "feature",
+			currentRunID: "3",
+			token:        "test-token",
+			want:         "",
+		},
+		{
+			name:         "any_branch",
+			workflowFile: "ci.yml",
+			branch:       "",
+			currentRunID: "5",
+			token:        "test-token",
+			want:         "failure",
+		},
+		{
+			name:         "unknown_workflow_file",
+			workflowFile: "",
+			branch:       "main",
+			token:        "test-token",
+			wantErr:      true,
+		},
+		{
+			name:         "api_error",
+			workflowFile: "ci.yml",
+			branch:       "main",
+			token:        "wrong-token",
+			wantErr:      true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &githubClient{
+				httpClient: srv.Client(),
+				baseURL:    srv.URL,
+				token:      tc.token,
+			}
+			got, err := g.previousConclusion(context.Background(), "test-org/test-repo", tc.workflowFile, tc.branch, tc.currentRunID)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("previousConclusion() got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("previousConclusion() got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGitHubClient_PreviousTag(t *testing.T) {
+	t.Parallel()
+
+	srv := newFakeGitHub(t, `[]`)
+
+	cases := []struct {
+		name    string
+		tag     string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "release",
+			tag:   "v1.3.0",
+			token: "test-token",
+			want:  "v1.2.0",
+		},
+		{
+			name:  "pre_release",
+			tag:   "v1.3.0-rc.2",
+			token: "test-token",
+			want:  "v1.3.0-rc.1",
+		},
+		{
+			name:  "numeric_order",
+			tag:   "v1.11.0",
+			token: "test-token",
+			want:  "v1.10.0",
+		},
+		{
+			name:  "first",
+			tag:   "v1.0.0",
+			token: "test-token",
+			want:  "",
+		},
+		{
+			name:  "not_a_version",
+			tag:   "nightly",
+			token: "wrong-token",
+			want:  "",
+		},
+		{
+			name:    "api_error",
+			tag:     "v1.3.0",
+			token:   "wrong-token",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			g := &githubClient{
+				httpClient: srv.Client(),
+				baseURL:    srv.URL,
+				token:      tc.token,
+			}
+			got, err := g.previousTag(context.Background(), "test-org/test-repo", tc.tag)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("previousTag() got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("previousTag() got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStatusChange(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		previous string
+		current  string
+		want     string
+	}{
+		{previous: "", current: "failure", want: statusChangeFirst},
+		{previous: "success", current: "failure", want: statusChangeBroken},
+		{previous: "success", current: "cancelled", want: statusChangeBroken},
+		{previous: "timed_out", current: "success", want: statusChangeFixed},
+		{previous: "failure", current: "failure", want: statusChangeUnchanged},
+		{previous: "success", current: "success", want: statusChangeUnchanged},
+	}
+
+	for _, tc := range cases {
+		if got := statusChange(tc.previous, tc.current); got != tc.want {
+			t.Errorf("statusChange(%q, %q) got %q, want %q", tc.previous, tc.current, got, tc.want)
+		}
+	}
+}
+
+func TestWorkflowFileAndBranch(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		ghJSON     map[string]any
+		wantFile   string
+		wantBranch string
+	}{
+		{
+			name: "push",
+			ghJSON: map[string]any{
+				"workflow_ref": "test-org/test-repo/.github/workflows/ci.yml@refs/heads/main",
+				"ref":          "refs/heads/main",
+			},
+			wantFile:   "ci.yml",
+			wantBranch: "main",
+		},
+		{
+			name: "pull_request",
+			ghJSON: map[string]any{
+				"workflow_ref": "test-org/test-repo/.github/workflows/ci.yml@refs/pull/1/merge",
+				"ref":          "refs/pull/1/merge",
+				"head_ref":     "feature",
+			},
+			wantFile:   "ci.yml",
+			wantBranch: "feature",
+		},
+		{
+			name: "tag",
+			ghJSON: map[string]any{
+				"workflow_ref": "test-org/test-repo/.github/workflows/release.yml@refs/tags/v1.2.3",
+				"ref":          "refs/tags/v1.2.3",
+			},
+			wantFile:   "release.yml",
+			wantBranch: "",
+		},
+		{
+			name:   "missing_workflow_ref",
+			ghJSON: map[string]any{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			gotFile, gotBranch := workflowFileAndBranch(tc.ghJSON)
+			if gotFile != tc.wantFile || gotBranch != tc.wantBranch {
+				t.Errorf("workflowFileAndBranch() got (%q, %q), want (%q, %q)", gotFile, gotBranch, tc.wantFile, tc.wantBranch)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+)
+
+// idempotencyKey returns a key identifying the notification of a job with the
+// given status from a step, so that sending it again, e.g. when retrying, is
+// collapsed by Chat. The matrix is part of the key, as the jobs of a matrix
+// share their id, and so is the step, so that e.g. a started and a finished
+// notification of the same job are both sent. The attempt is not, so that
+// re-running a job doesn't post the same outcome again, while a different
+// status still does. It returns an empty string outside of a workflow run.
+func idempotencyKey(ghJSON, jobJSON, matrixJSON map[string]any, status string) string {
+	runID := githubevents.StringValue(ghJSON, "run_id")
+	if runID == "" {
+		return ""
+	}
+
+	// Maps are marshaled with sorted keys, so the matrix is stable.
+	matrix, _ := json.Marshal(matrixJSON)
+	parts := []string{
+		githubevents.StringValue(ghJSON, githubevents.RepositoryKey),
+		runID,
+		githubevents.StringValue(ghJSON, "job"),
+		githubevents.StringValue(ghJSON, "action"),
+		string(matrix),
+		status,
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// setIdempotencyKey sets the parameters for the key on the URLs of messages.
+func setIdempotencyKey(messages []*outgoingMessage, key string) error {
+	for _, msg := range messages {
+		u, err := msg.backend().WithIdempotencyKey(msg.url, key)
+		if err != nil {
+			return fmt.Errorf("%s: %w", msg.name, err)
+		}
+		msg.url = u
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"testing"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	t.Parallel()
+
+	ghJSON := func(attempt, action string) map[string]any {
+		return map[string]any{
+			"repository":  "test-org/test-repo",
+			"run_id":      "1234567890",
+			"run_attempt": attempt,
+			"job":         "build",
+			"action":      action,
+		}
+	}
+	matrix := map[string]any{"os": "ubuntu-latest", "go": "1.24"}
+
+	key := idempotencyKey(ghJSON("1", "notify-started"), nil, matrix, "success")
+	if key == "" {
+		t.Fatal("idempotencyKey() got an empty key")
+	}
+	if got := idempotencyKey(ghJSON("1", "notify-started"), nil, map[string]any{"go": "1.24", "os": "ubuntu-latest"}, "success"); got != key {
+		t.Errorf("idempotencyKey() got %q for the same notification, want %q", got, key)
+	}
+	if got := idempotencyKey(ghJSON("2", "notify-started"), nil, matrix, "success"); got != key {
+		t.Errorf("idempotencyKey() got %q for the same notification of a re-run, want %q", got, key)
+	}
+
+	for name, other := range map[string]string{
+		"matrix": idempotencyKey(ghJSON("1", "notify-started"), nil, map[string]any{"os": "windows-latest", "go": "1.24"}, "success"),
+		"status": idempotencyKey(ghJSON("1", "notify-started"), nil, matrix, "failure"),
+		"step":   idempotencyKey(ghJSON("1", "notify-finished"), nil, matrix, "success"),
+	} {
+		if other == key {
+			t.Errorf("idempotencyKey() got the same key for a different %s", name)
+		}
+	}
+
+	if got := idempotencyKey(map[string]any{"repository": "test-org/test-repo"}, nil, nil, "success"); got != "" {
+		t.Errorf("idempotencyKey() got %q outside of a workflow run, want an empty key", got)
+	}
+}
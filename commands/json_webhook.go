@@ -0,0 +1,106 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+// jsonSigningSecretEnvKey is the environment variable holding the secret
+// signing the requests of the JSON backend.
+const jsonSigningSecretEnvKey = "JSON_WEBHOOK_SIGNING_SECRET"
+
+// jsonWebhookFlags are the flags configuring the JSON backend, shared by the
+// commands sending messages.
+type jsonWebhookFlags struct {
+	template      string
+	headers       []string
+	signingSecret string
+}
+
+// register adds the flags to set.
+func (f *jsonWebhookFlags) register(set *cli.FlagSet) {
+	s := set.NewSection("JSON WEBHOOK OPTIONS")
+
+	s.StringVar(&cli.StringVar{
+		Name:    "json-template",
+		Example: `{"summary": {{ json .Title }}, "severity": "high"}`,
+		Target:  &f.template,
+		Usage: `Go template of the request body sent to json+https:// webhook ` +
+			`urls. The json function encodes a value as JSON. By default the ` +
+			`whole template data is sent, without the .GitHub and .Job contexts ` +
+			`which templates can opt into. Secrets such as github.token are ` +
+			`removed from the contexts.`,
+	})
+
+	stringsVar(s, &cli.StringSliceVar{
+		Name:    "json-header",
+		Example: "Authorization: Bearer <TOKEN>",
+		Target:  &f.headers,
+		Usage: `Header sent to json+https:// webhook urls, as NAME: VALUE. ` +
+			`Repeat the flag or separate headers with newlines to send several.`,
+	})
+
+	s.StringVar(&cli.StringVar{
+		Name:    "json-signing-secret",
+		EnvVar:  jsonSigningSecretEnvKey,
+		Target:  &f.signingSecret,
+		Example: "<SECRET>",
+		Usage: `Secret signing the requests to json+https:// webhook urls. ` +
+			`The HMAC-SHA256 of the body is sent in the ` + render.JSONSignatureHeader +
+			` header as sha256=<hex>.`,
+	})
+}
+
+// notifiers returns the supported backends by name, with the JSON backend
+// configured by the flags.
+func (f *jsonWebhookFlags) notifiers() (map[string]render.Notifier, error) {
+	n := &render.JSON{}
+	if f.template != "" {
+		tmpl, err := render.NewJSONTemplate(f.template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json template: %w", err)
+		}
+		n.Template = tmpl
+	}
+	var headers []string
+	for _, v := range f.headers {
+		headers = append(headers, strings.Split(v, "\n")...)
+	}
+	for _, h := range headers {
+		if strings.TrimSpace(h) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(h, ":")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			// The header values may be secrets, so they are not part of
+			// the error.
+			return nil, fmt.Errorf("invalid json header, must be NAME: VALUE")
+		}
+		if n.Header == nil {
+			n.Header = http.Header{}
+		}
+		n.Header.Add(k, strings.TrimSpace(v))
+	}
+	if f.signingSecret != "" {
+		n.Secret = []byte(f.signingSecret)
+	}
+	return render.Notifiers(n), nil
+}
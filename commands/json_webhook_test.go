@@ -0,0 +1,119 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSONWebhookFlags_Notifiers(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name       string
+		env        map[string]string
+		args       []string
+		wantHeader http.Header
+		wantSecret string
+		wantBody   string
+		expErr     string
+	}{
+		{
+			name:     "defaults",
+			wantBody: `{"title":"hello"`,
+		},
+		{
+			name: "headers",
+			args: []string{
+				"-json-header", "Authorization: Bearer token\nX-Source: ci",
+				"-json-header", "Accept: application/json, text/plain",
+			},
+			wantHeader: http.Header{
+				"Authorization": {"Bearer token"},
+				"X-Source":      {"ci"},
+				"Accept":        {"application/json, text/plain"},
+			},
+			wantBody: `{"title":"hello"`,
+		},
+		{
+			name:       "signing_secret",
+			env:        map[string]string{jsonSigningSecretEnvKey: "secret"},
+			wantSecret: "secret",
+			wantBody:   `{"title":"hello"`,
+		},
+		{
+			name:     "template",
+			args:     []string{"-json-template", `{"summary": {{ json .Title }}}`},
+			wantBody: `{"summary":"hello"}`,
+		},
+		{
+			name:   "invalid_template",
+			args:   []string{"-json-template", `{{ .Title`},
+			expErr: "invalid json template",
+		},
+		{
+			name:   "invalid_header",
+			args:   []string{"-json-header", "Bearer secret"},
+			expErr: "invalid json header, must be NAME: VALUE",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var flags jsonWebhookFlags
+			set := cli.NewFlagSet(cli.WithLookupEnv(cli.MapLookuper(tc.env)))
+			flags.register(set)
+			if err := set.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+
+			ns, err := flags.notifiers()
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+
+			n, ok := ns["json"].(*render.JSON)
+			if !ok {
+				t.Fatalf("got json backend %T, want *render.JSON", ns["json"])
+			}
+			if diff := cmp.Diff(tc.wantHeader, n.Header); diff != "" {
+				t.Errorf("header got unexpected diff (-want, +got):\n%s", diff)
+			}
+			if got := string(n.Secret); got != tc.wantSecret {
+				t.Errorf("secret got %q, want %q", got, tc.wantSecret)
+			}
+			body, err := n.RequestBody(&cards.Message{Title: "hello"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(body); !strings.HasPrefix(got, tc.wantBody) {
+				t.Errorf("body got %s, want prefix %s", got, tc.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,521 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+const (
+	outboxFileName = "outbox.log"
+
+	// maxOutboxDeliveries is the number of delivery ids remembered to
+	// deduplicate redeliveries after the outbox is reopened.
+	maxOutboxDeliveries = 10000
+
+	// outboxCompactSize is the size of the records appended to the log after
+	// which it is compacted, even though messages are still pending. It is
+	// compacted as well whenever no message is pending.
+	outboxCompactSize = 64 << 20
+
+	outboxMinBackoff = time.Second
+	outboxMaxBackoff = 5 * time.Minute
+)
+
+// Operations of the outbox log records.
+const (
+	outboxOpEnqueue  = "enqueue"
+	outboxOpDone     = "done"
+	outboxOpDelivery = "delivery"
+)
+
+// outboxRecord is a line of the outbox log. The log is replayed when the
+// outbox is opened, enqueued messages without a done record are pending.
+type outboxRecord struct {
+	Op          string `json:"op"`
+	ID          int64  `json:"id,omitempty"`
+	Delivery    string `json:"delivery,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Name        string `json:"name,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Backend     string `json:"backend,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// outboxEntry is a pending message.
+type outboxEntry struct {
+	id       int64
+	delivery string
+	message  *outgoingMessage
+}
+
+// outbox persists rendered messages in an append-only log before they are
+// sent, so that they survive Chat outages and restarts. Messages are sent in
+// order per destination, and retried with backoff until they are sent or
+// rejected for good. Destinations are keyed by the hash of their URL.
+type outbox struct {
+	// send sends a message, and backoff returns the delay before the given
+	// retry. They are overridden in tests.
+	send    func(ctx context.Context, msg *outgoingMessage) error
+	backoff func(retry int) time.Duration
+	logf    func(format string, args ...any)
+
+	mu            sync.Mutex
+	path          string
+	f             *os.File
+	written       int64
+	nextID        int64
+	queues        map[string][]*outboxEntry
+	wake          map[string]chan struct{}
+	delivered     map[string]struct{}
+	deliveryOrder []string
+	ctx           context.Context //nolint:containedctx // Set by start for the workers
+	wg            sync.WaitGroup
+}
+
+// openOutbox opens the outbox in dir, replaying and compacting its log. The
+// backends of pending messages are looked up by name in ns. The log is
+// compacted again while the outbox is used, so that it doesn't keep the bodies
+// and URLs of sent messages.
+func openOutbox(dir string, client *http.Client, ns map[string]render.Notifier) (*outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create outbox dir: %w", err)
+	}
+
+	o := &outbox{
+		send: func(ctx context.Context, msg *outgoingMessage) error {
+			_, _, err := msg.backend().Send(ctx, client, msg.url, msg.body)
+			return err
+		},
+		backoff:   outboxBackoff,
+		logf:      func(string, ...any) {},
+		queues:    map[string][]*outboxEntry{},
+		wake:      map[string]chan struct{}{},
+		delivered: map[string]struct{}{},
+		path:      filepath.Join(dir, outboxFileName),
+	}
+
+	if err := o.replay(o.path, ns); err != nil {
+		return nil, err
+	}
+	if err := o.rotate(); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// replay rebuilds the queues and the delivery ids from the log at pth, with the
+// backends of the messages looked up in ns.
+func (o *outbox) replay(pth string, ns map[string]render.Notifier) error {
+	f, err := os.Open(pth)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	defer f.Close()
+
+	pending := map[int64]*outboxEntry{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64<<10), 2*maxPayloadSize)
+	for sc.Scan() {
+		var r outboxRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			// A record cut short, e.g. by a crash or a full disk, was never
+			// acknowledged. The records after it are still valid.
+			continue
+		}
+		switch r.Op {
+		case outboxOpEnqueue:
+			pending[r.ID] = &outboxEntry{
+				id:       r.ID,
+				delivery: r.Delivery,
+				message: &outgoingMessage{
+					name:     r.Name,
+					url:      r.URL,
+					body:     r.Body,
+					notifier: ns[r.Backend],
+				},
+			}
+			o.markDelivered(r.Delivery)
+		case outboxOpDone:
+			delete(pending, r.ID)
+		case outboxOpDelivery:
+			o.markDelivered(r.Delivery)
+		}
+		o.nextID = max(o.nextID, r.ID)
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	ids := make([]int64, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		e := pending[id]
+		key := destinationKey(e.message.url)
+		o.queues[key] = append(o.queues[key], e)
+	}
+	return nil
+}
+
+// compact rewrites the log at pth with only the pending messages and the
+// remembered delivery ids.
+func (o *outbox) compact(pth string) error {
+	tmp := pth + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+
+	pendingDeliveries := map[string]struct{}{}
+	var records []*outboxRecord
+	for key, q := range o.queues {
+		for _, e := range q {
+			pendingDeliveries[e.delivery] = struct{}{}
+			records = append(records, &outboxRecord{
+				Op:          outboxOpEnqueue,
+				ID:          e.id,
+				Delivery:    e.delivery,
+				Destination: key,
+				Name:        e.message.name,
+				URL:         e.message.url,
+				Backend:     e.message.backend().Name(),
+				Body:        e.message.body,
+			})
+		}
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	for _, d := range o.deliveryOrder {
+		if _, ok := pendingDeliveries[d]; !ok {
+			records = append(records, &outboxRecord{Op: outboxOpDelivery, Delivery: d})
+		}
+	}
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact outbox: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := os.Rename(tmp, pth); err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	return nil
+}
+
+// rotate compacts the log and reopens it for appending. It must be called with
+// mu held once the outbox is in use.
+func (o *outbox) rotate() error {
+	if err := o.compact(o.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open outbox: %w", err)
+	}
+	if o.f != nil {
+		o.f.Close()
+	}
+	o.f = f
+	o.written = 0
+	return nil
+}
+
+// markDelivered remembers the delivery id, forgetting the oldest ones beyond
+// maxOutboxDeliveries. It must be called with mu held.
+func (o *outbox) markDelivered(delivery string) {
+	if delivery == "" {
+		return
+	}
+	if _, ok := o.delivered[delivery]; ok {
+		return
+	}
+	o.delivered[delivery] = struct{}{}
+	o.deliveryOrder = append(o.deliveryOrder, delivery)
+	if len(o.deliveryOrder) > maxOutboxDeliveries {
+		delete(o.delivered, o.deliveryOrder[0])
+		o.deliveryOrder = o.deliveryOrder[1:]
+	}
+}
+
+// enqueue persists the messages of a delivery. It returns false without
+// enqueuing anything if the delivery id was seen before, as GitHub redelivers
+// with the same id.
+func (o *outbox) enqueue(delivery string, messages []*outgoingMessage) (bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, ok := o.delivered[delivery]; ok && delivery != "" {
+		return false, nil
+	}
+
+	entries := make([]*outboxEntry, 0, len(messages))
+	var buf []byte
+	for _, msg := range messages {
+		o.nextID++
+		e := &outboxEntry{id: o.nextID, delivery: delivery, message: msg}
+		b, err := json.Marshal(&outboxRecord{
+			Op:          outboxOpEnqueue,
+			ID:          e.id,
+			Delivery:    delivery,
+			Destination: destinationKey(msg.url),
+			Name:        msg.name,
+			URL:         msg.url,
+			Backend:     msg.backend().Name(),
+			Body:        msg.body,
+		})
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal outbox record: %w", err)
+		}
+		buf = append(append(buf, b...), '\n')
+		entries = append(entries, e)
+	}
+	if _, err := o.f.Write(buf); err != nil {
+		return false, fmt.Errorf("failed to write outbox: %w", err)
+	}
+	o.written += int64(len(buf))
+	if err := o.f.Sync(); err != nil {
+		return false, fmt.Errorf("failed to sync outbox: %w", err)
+	}
+
+	o.markDelivered(delivery)
+	for _, e := range entries {
+		key := destinationKey(e.message.url)
+		o.queues[key] = append(o.queues[key], e)
+		o.notify(key)
+	}
+	return true, nil
+}
+
+// start sends the pending messages until ctx is done.
+func (o *outbox) start(ctx context.Context) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.ctx = ctx
+	for key := range o.queues {
+		o.notify(key)
+	}
+}
+
+// notify wakes up the worker of the destination, starting it if needed. It
+// must be called with mu held.
+func (o *outbox) notify(key string) {
+	if o.ctx == nil {
+		return
+	}
+	ch, ok := o.wake[key]
+	if !ok {
+		ch = make(chan struct{}, 1)
+		o.wake[key] = ch
+		o.wg.Add(1)
+		go o.worker(o.ctx, key, ch)
+	}
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// worker sends the messages of a destination one at a time, in order.
+func (o *outbox) worker(ctx context.Context, key string, wake <-chan struct{}) {
+	defer o.wg.Done()
+
+	retry := 0
+	for {
+		o.mu.Lock()
+		var e *outboxEntry
+		if q := o.queues[key]; len(q) > 0 {
+			e = q[0]
+		}
+		o.mu.Unlock()
+
+		if e == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-wake:
+				continue
+			}
+		}
+
+		err := o.send(ctx, e.message)
+		if err != nil && !isPermanentDeliveryError(err) {
+			retry++
+			o.logf("%s: failed, retry %d: %s", e.message.name, retry, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(o.backoff(retry)):
+				continue
+			}
+		}
+
+		if err != nil {
+			o.logf("%s: dropped: %s", e.message.name, err)
+		} else {
+			o.logf("%s: sent", e.message.name)
+		}
+		retry = 0
+		if err := o.complete(key, e); err != nil {
+			o.logf("%s: %s", e.message.name, err)
+		}
+	}
+}
+
+// complete removes the entry at the head of the queue of the destination. The
+// log is compacted when no message is pending anymore or it grew too large.
+func (o *outbox) complete(key string, e *outboxEntry) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.queues[key] = o.queues[key][1:]
+	if len(o.queues[key]) == 0 {
+		delete(o.queues, key)
+	}
+
+	b, err := json.Marshal(&outboxRecord{Op: outboxOpDone, ID: e.id})
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox record: %w", err)
+	}
+	if _, err := o.f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	o.written += int64(len(b) + 1)
+
+	if len(o.queues) == 0 || o.written >= outboxCompactSize {
+		return o.rotate()
+	}
+	return nil
+}
+
+// outboxStatus is the state of the outbox reported by the status endpoint.
+type outboxStatus struct {
+	Pending      int                  `json:"pending"`
+	Destinations []*destinationStatus `json:"destinations"`
+}
+
+// destinationStatus is the queue depth of a destination. Name is the name of
+// the oldest pending message, destination URLs contain secrets.
+type destinationStatus struct {
+	Destination string `json:"destination"`
+	Name        string `json:"name"`
+	Depth       int    `json:"depth"`
+}
+
+// status returns the queue depth per destination.
+func (o *outbox) status() *outboxStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	s := &outboxStatus{Destinations: []*destinationStatus{}}
+	for key, q := range o.queues {
+		s.Pending += len(q)
+		s.Destinations = append(s.Destinations, &destinationStatus{
+			Destination: key,
+			Name:        q[0].message.name,
+			Depth:       len(q),
+		})
+	}
+	sort.Slice(s.Destinations, func(i, j int) bool {
+		return s.Destinations[i].Destination < s.Destinations[j].Destination
+	})
+	return s
+}
+
+// close waits for the workers, whose context must be done, and closes the log.
+func (o *outbox) close() error {
+	o.wg.Wait()
+	if err := o.f.Close(); err != nil {
+		return fmt.Errorf("failed to close outbox: %w", err)
+	}
+	return nil
+}
+
+// destinationKey identifies a destination without revealing its URL. The
+// idempotency parameters differ for every message, so they are ignored.
+func destinationKey(u string) string {
+	if parsed, err := url.Parse(u); err == nil {
+		q := parsed.Query()
+		q.Del("requestId")
+		q.Del("messageId")
+		parsed.RawQuery = q.Encode()
+		u = parsed.String()
+	}
+	sum := sha256.Sum256([]byte(u))
+	return hex.EncodeToString(sum[:8])
+}
+
+// outboxBackoff doubles the delay with every retry, up to outboxMaxBackoff.
+func outboxBackoff(retry int) time.Duration {
+	d := outboxMinBackoff
+	for i := 1; i < retry && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
+
+// isPermanentDeliveryError returns true for errors that retrying won't fix,
+// e.g. an invalid card or a deleted space, so that such a message does not
+// block the messages queued after it.
+func isPermanentDeliveryError(err error) bool {
+	var statusCode int
+	var cerr *chat.APIError
+	var herr *chat.HTTPStatusError
+	switch {
+	case errors.As(err, &cerr):
+		statusCode = cerr.StatusCode
+	case errors.As(err, &herr):
+		statusCode = herr.StatusCode
+	default:
+		return false
+	}
+	return statusCode >= 400 && statusCode < 500 &&
+		statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,310 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// newTestOutbox opens an outbox in a temp dir, sending with send.
+func newTestOutbox(t *testing.T, dir string, send func(ctx context.Context, msg *outgoingMessage) error) *outbox {
+	t.Helper()
+
+	ob, err := openOutbox(dir, http.DefaultClient, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ob.send = send
+	ob.backoff = func(int) time.Duration { return time.Millisecond }
+	return ob
+}
+
+// waitForEmptyOutbox waits until the outbox has no pending messages.
+func waitForEmptyOutbox(t *testing.T, ob *outbox) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for ob.status().Pending > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("outbox still has %d pending messages", ob.status().Pending)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestOutbox_OrderAndRetry(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var sent []string
+	failures := map[string]int{"a1": 3}
+	ob := newTestOutbox(t, t.TempDir(), func(ctx context.Context, msg *outgoingMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures[string(msg.body)] > 0 {
+			failures[string(msg.body)]--
+			return errors.New("unavailable")
+		}
+		sent = append(sent, string(msg.body))
+		return nil
+	})
+
+	for _, d := range []struct {
+		delivery string
+		messages []*outgoingMessage
+	}{
+		{"1", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a1")}, {name: "b", url: "https://b", body: []byte("b1")}}},
+		{"2", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a2")}}},
+		{"3", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a3")}}},
+	} {
+		if ok, err := ob.enqueue(d.delivery, d.messages); err != nil || !ok {
+			t.Fatalf("enqueue(%s) got %t, %v", d.delivery, ok, err)
+		}
+	}
+
+	wantStatus := &outboxStatus{
+		Pending: 4,
+		Destinations: []*destinationStatus{
+			{Destination: destinationKey("https://a"), Name: "a", Depth: 3},
+			{Destination: destinationKey("https://b"), Name: "b", Depth: 1},
+		},
+	}
+	if diff := cmp.Diff(wantStatus, ob.status(), cmpopts.SortSlices(func(a, b *destinationStatus) bool {
+		return a.Destination < b.Destination
+	})); diff != "" {
+		t.Errorf("status() got unexpected diff (-want, +got):\n%s", diff)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ob.start(ctx)
+	waitForEmptyOutbox(t, ob)
+	cancel()
+	if err := ob.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var gotA []string
+	for _, s := range sent {
+		if s[0] == 'a' {
+			gotA = append(gotA, s)
+		}
+	}
+	if diff := cmp.Diff([]string{"a1", "a2", "a3"}, gotA); diff != "" {
+		t.Errorf("got unexpected order for destination a (-want, +got):\n%s", diff)
+	}
+	if got, want := len(sent), 4; got != want {
+		t.Errorf("got %d messages sent, want %d", got, want)
+	}
+}
+
+func TestOutbox_PermanentError(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var attempts int
+	ob := newTestOutbox(t, t.TempDir(), func(ctx context.Context, msg *outgoingMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		return chat.ParseAPIError(http.StatusBadRequest, []byte(`{"error":{"status":"INVALID_ARGUMENT"}}`))
+	})
+
+	if _, err := ob.enqueue("1", []*outgoingMessage{{name: "a", url: "https://a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ob.start(ctx)
+	waitForEmptyOutbox(t, ob)
+	cancel()
+	if err := ob.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got, want := attempts, 1; got != want {
+		t.Errorf("got %d attempts, want %d", got, want)
+	}
+}
+
+func TestOutbox_Reopen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	unavailable := func(ctx context.Context, msg *outgoingMessage) error { return errors.New("unavailable") }
+
+	ob := newTestOutbox(t, dir, unavailable)
+	if _, err := ob.enqueue("1", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a1")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ob.enqueue("2", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a2")}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ob.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pending messages are sent after reopening, and redeliveries are
+	// still detected.
+	var mu sync.Mutex
+	var sent []string
+	ob = newTestOutbox(t, dir, func(ctx context.Context, msg *outgoingMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sent = append(sent, string(msg.body))
+		return nil
+	})
+	if got, want := ob.status().Pending, 2; got != want {
+		t.Errorf("status() got %d pending messages after reopening, want %d", got, want)
+	}
+	if ok, err := ob.enqueue("1", []*outgoingMessage{{name: "a", url: "https://a", body: []byte("a1")}}); err != nil || ok {
+		t.Errorf("enqueue() of a redelivery got %t, %v, want false", ok, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ob.start(ctx)
+	waitForEmptyOutbox(t, ob)
+	cancel()
+	if err := ob.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	if diff := cmp.Diff([]string{"a1", "a2"}, sent); diff != "" {
+		t.Errorf("got unexpected messages sent (-want, +got):\n%s", diff)
+	}
+	mu.Unlock()
+
+	// Sent messages are not sent again, but their deliveries are remembered.
+	ob = newTestOutbox(t, dir, unavailable)
+	t.Cleanup(func() { ob.close() })
+	if got, want := ob.status().Pending, 0; got != want {
+		t.Errorf("status() got %d pending messages, want %d", got, want)
+	}
+	if ok, err := ob.enqueue("2", nil); err != nil || ok {
+		t.Errorf("enqueue() of a redelivery got %t, %v, want false", ok, err)
+	}
+}
+
+func TestOutbox_CompactWhenDrained(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	ob := newTestOutbox(t, dir, func(ctx context.Context, msg *outgoingMessage) error { return nil })
+	if _, err := ob.enqueue("1", []*outgoingMessage{{name: "a", url: "https://a?token=test-token", body: []byte("test-body")}}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ob.start(ctx)
+	waitForEmptyOutbox(t, ob)
+	cancel()
+	if err := ob.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, outboxFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(b)
+	for _, v := range []string{"test-token", "dGVzdC1ib2R5"} {
+		if strings.Contains(got, v) {
+			t.Errorf("outbox log %q still holds %q of a sent message", got, v)
+		}
+	}
+	if !strings.Contains(got, `"delivery":"1"`) {
+		t.Errorf("outbox log %q lost the delivery id", got)
+	}
+}
+
+func TestOutbox_ReplaySkipsTornRecord(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	log := strings.Join([]string{
+		`{"op":"enqueue","id":1,"delivery":"1","name":"a","url":"https://a","body":"YTE="}`,
+		`{"op":"enqueue","id":2,"deliv`,
+		`{"op":"enqueue","id":3,"delivery":"3","name":"a","url":"https://a","body":"YTM="}`,
+		`{"op":"done","id":1}`,
+	}, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, outboxFileName), []byte(log), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ob := newTestOutbox(t, dir, nil)
+	t.Cleanup(func() { ob.close() })
+	want := &outboxStatus{
+		Pending:      1,
+		Destinations: []*destinationStatus{{Destination: destinationKey("https://a"), Name: "a", Depth: 1}},
+	}
+	if diff := cmp.Diff(want, ob.status()); diff != "" {
+		t.Errorf("status() got unexpected diff (-want, +got):\n%s", diff)
+	}
+	if ok, err := ob.enqueue("3", nil); err != nil || ok {
+		t.Errorf("enqueue() of a redelivery got %t, %v, want false", ok, err)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		retry int
+		want  time.Duration
+	}{
+		{retry: 1, want: time.Second},
+		{retry: 2, want: 2 * time.Second},
+		{retry: 4, want: 8 * time.Second},
+		{retry: 100, want: outboxMaxBackoff},
+	}
+	for _, tc := range cases {
+		if got := outboxBackoff(tc.retry); got != tc.want {
+			t.Errorf("outboxBackoff(%d) got %s, want %s", tc.retry, got, tc.want)
+		}
+	}
+}
+
+func TestDestinationKey(t *testing.T) {
+	t.Parallel()
+
+	u := "https://chat.googleapis.com/v1/spaces/AAAA/messages?key=test-key&token=test-token"
+	withKey, err := chat.WithIdempotencyKey(u, "test-idempotency-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := destinationKey(withKey), destinationKey(u); got != want {
+		t.Errorf("destinationKey() got %q with an idempotency key, want %q", got, want)
+	}
+	if destinationKey(u) == destinationKey(u+"&threadKey=other") {
+		t.Errorf("destinationKey() got the same key for different destinations")
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+const (
+	githubOutputEnvKey      = "GITHUB_OUTPUT"
+	githubStepSummaryEnvKey = "GITHUB_STEP_SUMMARY"
+)
+
+// Reasons for not sending anything, reported as the skipped_reason output.
+const (
+	skippedFirstAttemptFailure = "first_attempt_failure"
+	skippedStatusUnchanged     = "status_unchanged"
+	skippedQuietHours          = "quiet_hours"
+	skippedNoRoute             = "no_route"
+)
+
+// runOutputs are the step outputs of a notification, so that later steps can
+// e.g. reply in the same thread.
+type runOutputs struct {
+	// statusCode is the HTTP status code of the first destination.
+	statusCode int
+	// messageName and threadName are those of the first message sent.
+	messageName string
+	threadName  string
+	// destinations is the number of destinations the message was sent to.
+	destinations  int
+	skippedReason string
+	// err is the delivery error, if any.
+	err string
+}
+
+// outputsFromResults returns the outputs after sending to every destination.
+func outputsFromResults(results []*deliveryResult) *runOutputs {
+	o := &runOutputs{}
+	for i, r := range results {
+		if i == 0 {
+			o.statusCode = r.statusCode
+		}
+		if r.err != nil {
+			continue
+		}
+		if o.destinations == 0 && r.sent != nil {
+			o.messageName = r.sent.Name
+			o.threadName = r.sent.Thread.Name
+		}
+		o.destinations++
+	}
+	return o
+}
+
+// values returns the outputs by output name.
+func (o *runOutputs) values() map[string]string {
+	statusCode := ""
+	if o.statusCode != 0 {
+		statusCode = strconv.Itoa(o.statusCode)
+	}
+	return map[string]string{
+		"status_code":    statusCode,
+		"message_name":   o.messageName,
+		"thread_name":    o.threadName,
+		"destinations":   strconv.Itoa(o.destinations),
+		"skipped_reason": o.skippedReason,
+		"error":          o.err,
+	}
+}
+
+// chatAPIErrorOutputs returns the outputs after the Chat API failed with err.
+func chatAPIErrorOutputs(err error) *runOutputs {
+	o := &runOutputs{}
+	var cerr *chat.APIError
+	if errors.As(err, &cerr) {
+		o.statusCode = cerr.StatusCode
+	}
+	return o
+}
+
+// escapeWorkflowCommandData escapes s for use as the message of a workflow
+// command such as ::warning::.
+func escapeWorkflowCommandData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// setGitHubOutputs appends step outputs to the file at pth, which is
+// $GITHUB_OUTPUT when running in GitHub Actions. The multiline syntax is used so
+// values can contain newlines. It is a no-op when pth is empty, e.g. when
+// running outside of GitHub Actions.
+func setGitHubOutputs(pth string, outputs map[string]string) error {
+	if pth == "" || len(outputs) == 0 {
+		return nil
+	}
+
+	rnd := make([]byte, 8)
+	if _, err := rand.Read(rnd); err != nil {
+		return fmt.Errorf("failed to generate output delimiter: %w", err)
+	}
+	delimiter := "ghadelimiter_" + hex.EncodeToString(rnd)
+
+	keys := make([]string, 0, len(outputs))
+	for k := range outputs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s<<%s\n%s\n%s\n", k, delimiter, outputs[k], delimiter)
+	}
+
+	f, err := os.OpenFile(pth, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", githubOutputEnvKey, err)
+	}
+	if _, err := f.WriteString(b.String()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", githubOutputEnvKey, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", githubOutputEnvKey, err)
+	}
+	return nil
+}
+
+// appendStepSummary appends the Markdown rendering of the card to the file at
+// pth, which is $GITHUB_STEP_SUMMARY when running in GitHub Actions. It is a
+// no-op when pth is empty.
+func appendStepSummary(pth string, m *cards.Message) error {
+	if pth == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(pth, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", githubStepSummaryEnvKey, err)
+	}
+	if _, err := f.WriteString(cards.Markdown(m)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", githubStepSummaryEnvKey, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", githubStepSummaryEnvKey, err)
+	}
+	return nil
+}
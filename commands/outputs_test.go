@@ -0,0 +1,137 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google/go-cmp/cmp"
+)
+
+// readGitHubOutputs parses the outputs written with setGitHubOutputs.
+func readGitHubOutputs(t *testing.T, pth string) map[string]string {
+	t.Helper()
+
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := map[string]string{}
+	lines := strings.Split(string(b), "\n")
+	for i := 0; i < len(lines); i++ {
+		name, delimiter, ok := strings.Cut(lines[i], "<<")
+		if !ok {
+			continue
+		}
+		var value []string
+		for i++; i < len(lines) && lines[i] != delimiter; i++ {
+			value = append(value, lines[i])
+		}
+		res[name] = strings.Join(value, "\n")
+	}
+	return res
+}
+
+func TestSetGitHubOutputs(t *testing.T) {
+	t.Parallel()
+
+	pth := filepath.Join(t.TempDir(), "github_output")
+	if err := os.WriteFile(pth, []byte("existing=value\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := setGitHubOutputs(pth, map[string]string{
+		"message_name": "spaces/AAAA/messages/BBBB",
+		"multiline":    "a\nb",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := regexp.MustCompile(`^existing=value\n` +
+		`message_name<<(ghadelimiter_[0-9a-f]+)\nspaces/AAAA/messages/BBBB\n(ghadelimiter_[0-9a-f]+)\n` +
+		`multiline<<(ghadelimiter_[0-9a-f]+)\na\nb\n(ghadelimiter_[0-9a-f]+)\n$`)
+	if !want.Match(b) {
+		t.Errorf("setGitHubOutputs() wrote %q, want it to match %q", b, want)
+	}
+
+	if err := setGitHubOutputs("", map[string]string{"message_name": "ignored"}); err != nil {
+		t.Errorf("setGitHubOutputs() without a file got unexpected error: %v", err)
+	}
+}
+
+func TestOutputsFromResults(t *testing.T) {
+	t.Parallel()
+
+	sent := &chat.Message{Name: "spaces/AAAA/messages/BBBB"}
+	sent.Thread.Name = "spaces/AAAA/threads/CCCC"
+
+	results := []*deliveryResult{
+		{statusCode: 404, err: errors.New("not found")},
+		{statusCode: 200, sent: sent},
+		{statusCode: 200, sent: &chat.Message{Name: "spaces/DDDD/messages/EEEE"}},
+	}
+
+	want := map[string]string{
+		"status_code":    "404",
+		"message_name":   "spaces/AAAA/messages/BBBB",
+		"thread_name":    "spaces/AAAA/threads/CCCC",
+		"destinations":   "2",
+		"skipped_reason": "",
+		"error":          "",
+	}
+	if diff := cmp.Diff(want, outputsFromResults(results).values()); diff != "" {
+		t.Errorf("outputsFromResults got unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestAppendStepSummary(t *testing.T) {
+	t.Parallel()
+
+	pth := filepath.Join(t.TempDir(), "step_summary")
+	m := &cards.Message{Title: "GitHub workflow succeeded"}
+	for range 2 {
+		if err := appendStepSummary(pth, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Count(string(b), "### GitHub workflow succeeded"), 2; got != want {
+		t.Errorf("appendStepSummary() wrote %d cards, want %d", got, want)
+	}
+}
+
+func TestEscapeWorkflowCommandData(t *testing.T) {
+	t.Parallel()
+
+	if got, want := escapeWorkflowCommandData("100% failed\r\n got body: {}"), "100%25 failed%0D%0A got body: {}"; got != want {
+		t.Errorf("escapeWorkflowCommandData() got %q, want %q", got, want)
+	}
+}
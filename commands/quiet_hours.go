@@ -0,0 +1,194 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+// Actions applied to notifications sent during quiet hours.
+const (
+	quietHoursActionSuppress      = "suppress"
+	quietHoursActionStripMentions = "strip_mentions"
+)
+
+// Decisions returned by quietHours.decide.
+const (
+	quietHoursDeliver       = "deliver"
+	quietHoursSuppress      = "suppress"
+	quietHoursStripMentions = "strip_mentions"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// quietHours is the schedule policy of the routing config. During quiet hours
+// notifications get the configured action applied, failures on protected
+// branches are always delivered as is and other failures only lose their
+// mentions.
+type quietHours struct {
+	TimeZone          string              `yaml:"time_zone"`
+	Windows           []*quietHoursWindow `yaml:"windows"`
+	Action            string              `yaml:"action"`
+	ProtectedBranches []string            `yaml:"protected_branches"`
+
+	location *time.Location
+}
+
+// quietHoursWindow is a daily time range, e.g. 20:00 to 08:00, on the given
+// days. A range ending before it starts continues on the next day, and a
+// window without start and end covers the whole day. Without days, the window
+// applies every day.
+type quietHoursWindow struct {
+	Days  []string `yaml:"days"`
+	Start string   `yaml:"start"`
+	End   string   `yaml:"end"`
+
+	start, end time.Duration
+}
+
+// validate checks the policy and resolves the time zone and the times of day.
+func (q *quietHours) validate() error {
+	var merr error
+
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		merr = errors.Join(merr, fmt.Errorf("invalid time_zone %q: %w", q.TimeZone, err))
+	}
+	q.location = loc
+
+	switch q.Action {
+	case "":
+		q.Action = quietHoursActionSuppress
+	case quietHoursActionSuppress, quietHoursActionStripMentions:
+	default:
+		merr = errors.Join(merr, fmt.Errorf("invalid action %q, must be one of %q or %q",
+			q.Action, quietHoursActionSuppress, quietHoursActionStripMentions))
+	}
+
+	if len(q.Windows) == 0 {
+		merr = errors.Join(merr, fmt.Errorf("at least one window is required"))
+	}
+	for i, w := range q.Windows {
+		for _, d := range w.Days {
+			if _, ok := weekdays[strings.ToLower(d)]; !ok {
+				merr = errors.Join(merr, fmt.Errorf("window #%d: invalid day %q", i+1, d))
+			}
+		}
+		if (w.Start == "") != (w.End == "") {
+			merr = errors.Join(merr, fmt.Errorf("window #%d: start and end must be set together", i+1))
+			continue
+		}
+		if w.Start == "" {
+			w.end = 24 * time.Hour
+			continue
+		}
+		var startErr, endErr error
+		if w.start, startErr = parseTimeOfDay(w.Start); startErr != nil {
+			merr = errors.Join(merr, fmt.Errorf("window #%d: %w", i+1, startErr))
+		}
+		if w.end, endErr = parseTimeOfDay(w.End); endErr != nil {
+			merr = errors.Join(merr, fmt.Errorf("window #%d: %w", i+1, endErr))
+		}
+		// An empty range would never be active, so the window would silently
+		// do nothing.
+		if startErr == nil && endErr == nil && w.start == w.end {
+			merr = errors.Join(merr, fmt.Errorf("window #%d: start and end must differ, omit both for the whole day", i+1))
+		}
+	}
+
+	for _, p := range q.ProtectedBranches {
+		if !isValidPattern(p) {
+			merr = errors.Join(merr, fmt.Errorf("invalid protected branch pattern %q", p))
+		}
+	}
+
+	return merr
+}
+
+// active returns true if now is within any of the windows.
+func (q *quietHours) active(now time.Time) bool {
+	now = now.In(q.location)
+	tod := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute
+	yesterday := (now.Weekday() + 6) % 7
+
+	for _, w := range q.Windows {
+		if w.start <= w.end {
+			if w.onDay(now.Weekday()) && tod >= w.start && tod < w.end {
+				return true
+			}
+			continue
+		}
+		// The window wraps past midnight.
+		if (w.onDay(now.Weekday()) && tod >= w.start) || (w.onDay(yesterday) && tod < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns what to do with a notification sent at now.
+func (q *quietHours) decide(now time.Time, failed bool, branch string) string {
+	if !q.active(now) {
+		return quietHoursDeliver
+	}
+	if failed {
+		if branch != "" && matchAny(q.ProtectedBranches, branch) {
+			return quietHoursDeliver
+		}
+		return quietHoursStripMentions
+	}
+	if q.Action == quietHoursActionStripMentions {
+		return quietHoursStripMentions
+	}
+	return quietHoursSuppress
+}
+
+// onDay returns true if the window applies on day.
+func (w *quietHoursWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.Days, func(d string) bool {
+		return weekdays[strings.ToLower(d)] == day
+	})
+}
+
+// parseTimeOfDay parses a HH:MM time of day into the duration since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, must be HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// stripMentions removes the user mentions, e.g. <users/all>, from s.
+func stripMentions(s string) string {
+	return strings.TrimSpace(render.MentionRe.ReplaceAllString(s, ""))
+}
@@ -0,0 +1,232 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Validate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			in: `
+quiet_hours:
+  time_zone: 'Europe/Berlin'
+  action: 'strip_mentions'
+  protected_branches: ['main']
+  windows:
+    - days: ['Sat', 'sun']
+    - start: '20:00'
+      end: '08:00'
+`,
+		},
+		{
+			name: "invalid_time_zone",
+			in: `
+quiet_hours:
+  time_zone: 'Mars/Olympus_Mons'
+  windows:
+    - days: ['sat']
+`,
+			wantErr: `invalid time_zone "Mars/Olympus_Mons"`,
+		},
+		{
+			name: "invalid_action",
+			in: `
+quiet_hours:
+  action: 'drop'
+  windows:
+    - days: ['sat']
+`,
+			wantErr: `invalid action "drop"`,
+		},
+		{
+			name: "no_windows",
+			in: `
+quiet_hours:
+  time_zone: 'UTC'
+`,
+			wantErr: "at least one window is required",
+		},
+		{
+			name: "invalid_day",
+			in: `
+quiet_hours:
+  windows:
+    - days: ['saturday']
+`,
+			wantErr: `window #1: invalid day "saturday"`,
+		},
+		{
+			name: "start_without_end",
+			in: `
+quiet_hours:
+  windows:
+    - start: '20:00'
+`,
+			wantErr: "window #1: start and end must be set together",
+		},
+		{
+			name: "empty_window",
+			in: `
+quiet_hours:
+  windows:
+    - start: '20:00'
+      end: '20:00'
+`,
+			wantErr: "window #1: start and end must differ",
+		},
+		{
+			name: "invalid_time_of_day",
+			in: `
+quiet_hours:
+  windows:
+    - start: '8pm'
+      end: '08:00'
+`,
+			wantErr: `window #1: invalid time of day "8pm"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseRoutingConfig([]byte(tc.in))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseRoutingConfig() got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseRoutingConfig() got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestQuietHours_Decide(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseRoutingConfig([]byte(`
+quiet_hours:
+  time_zone: 'America/New_York'
+  protected_branches: ['main', 'release/*']
+  windows:
+    - days: ['sat', 'sun']
+    - days: ['mon', 'tue', 'wed', 'thu', 'fri']
+      start: '20:00'
+      end: '08:00'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := cfg.QuietHours
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2023-04-24 is a Monday.
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2023, time.April, day, hour, minute, 0, 0, newYork)
+	}
+
+	cases := []struct {
+		name   string
+		now    time.Time
+		failed bool
+		branch string
+		want   string
+	}{
+		{
+			name: "working_hours",
+			now:  at(24, 12, 0),
+			want: quietHoursDeliver,
+		},
+		{
+			name: "monday_evening",
+			now:  at(24, 20, 0),
+			want: quietHoursSuppress,
+		},
+		{
+			name: "tuesday_early_morning",
+			now:  at(25, 7, 59),
+			want: quietHoursSuppress,
+		},
+		{
+			name: "tuesday_morning",
+			now:  at(25, 8, 0),
+			want: quietHoursDeliver,
+		},
+		{
+			name: "monday_early_morning_after_weekend",
+			now:  at(24, 7, 0),
+			want: quietHoursDeliver,
+		},
+		{
+			name: "saturday",
+			now:  at(29, 12, 0),
+			want: quietHoursSuppress,
+		},
+		{
+			name: "utc_clock",
+			now:  time.Date(2023, time.April, 25, 1, 0, 0, 0, time.UTC),
+			want: quietHoursSuppress,
+		},
+		{
+			name:   "failure_on_protected_branch",
+			now:    at(29, 12, 0),
+			failed: true,
+			branch: "release/1.0",
+			want:   quietHoursDeliver,
+		},
+		{
+			name:   "failure_on_other_branch",
+			now:    at(29, 12, 0),
+			failed: true,
+			branch: "feature",
+			want:   quietHoursStripMentions,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := q.decide(tc.now, tc.failed, tc.branch), tc.want; got != want {
+				t.Errorf("decide(%s) got %q, want %q", tc.now, got, want)
+			}
+		})
+	}
+}
+
+func TestStripMentions(t *testing.T) {
+	t.Parallel()
+
+	if got, want := stripMentions("<users/all> ci failed <users/123>"), "ci failed"; got != want {
+		t.Errorf("stripMentions() got %q, want %q", got, want)
+	}
+}
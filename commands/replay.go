@@ -0,0 +1,265 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+// ReplayCommand renders the message for a saved event payload, e.g. to
+// reproduce a card that looked wrong without rerunning the workflow.
+type ReplayCommand struct {
+	cli.BaseCommand
+	flagEventName    string
+	flagJobFile      string
+	flagPreviousTag  string
+	flagGitHubAPIURL string
+	flagGitHubToken  string
+	flagServerURL    string
+	flagBackend      string
+	flagWebhookURLs  []string
+	jsonFlags        jsonWebhookFlags
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+func (c *ReplayCommand) Desc() string {
+	return "Render or resend the message for a saved event payload"
+}
+
+func (c *ReplayCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options] EVENT_FILE
+
+  The replay command renders the message for a saved event payload, e.g. from
+  a webhook delivery log or GITHUB_EVENT_PATH, and prints the request body. A
+  file holding the whole github context, as given by toJson(github), is used
+  as is. With --webhook-url the message is sent instead.
+`
+}
+
+func (c *ReplayCommand) Flags() *cli.FlagSet {
+	set := c.NewFlagSet()
+
+	f := set.NewSection("COMMAND OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:    "event-name",
+		Example: "issues",
+		Target:  &c.flagEventName,
+		Usage: `Name of the event of the payload, as in the X-GitHub-Event ` +
+			`header of the webhook delivery. Not needed for a github context.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "job-file",
+		Example: "/path/to/job.json",
+		Target:  &c.flagJobFile,
+		Usage: `File holding the job context as JSON. By default the status is ` +
+			`the conclusion of the workflow run or job of the payload.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "previous-tag",
+		Example: "v1.2.2",
+		Target:  &c.flagPreviousTag,
+		Usage: `Tag released before the one being created, which cards for ` +
+			`created tags link to the compare view against. By default it is ` +
+			`looked up with the GitHub REST API, like when the workflow sent ` +
+			`the card.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "github-api-url",
+		Example: "https://api.github.com",
+		Default: defaultGitHubAPIURL,
+		EnvVar:  "GITHUB_API_URL",
+		Target:  &c.flagGitHubAPIURL,
+		Usage:   `Base URL of the GitHub REST API, used to look up previous tags.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "github-token",
+		EnvVar: "GITHUB_TOKEN",
+		Target: &c.flagGitHubToken,
+		Usage:  `Token used to call the GitHub REST API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "server-url",
+		Example: "https://github.com",
+		Default: "https://github.com",
+		Target:  &c.flagServerURL,
+		Usage:   `URL of the GitHub server the payload is from.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "backend",
+		Example: "slack",
+		Default: render.GoogleChat{}.Name(),
+		Target:  &c.flagBackend,
+		Usage: `Backend to render the printed request body for, one of ` +
+			`googlechat, slack, teams, discord or json.`,
+	})
+
+	stringsVar(f, &cli.StringSliceVar{
+		Name:    "webhook-url",
+		Example: "https://chat.googleapis.com/v1/spaces/<SPACE_ID>/messages?key=<KEY>&token=<TOKEN>",
+		Target:  &c.flagWebhookURLs,
+		Usage:   `Send the message to the webhook url instead of printing it.`,
+	})
+
+	c.jsonFlags.register(set)
+
+	return set
+}
+
+func (c *ReplayCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	args = f.Args()
+	if len(args) != 1 {
+		return fmt.Errorf("expected 1 argument, got %q", args)
+	}
+
+	ns, err := c.jsonFlags.notifiers()
+	if err != nil {
+		return err
+	}
+
+	payload, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read event file: %w", err)
+	}
+	ghJSON, err := c.githubContext(payload)
+	if err != nil {
+		return err
+	}
+	jobJSON := githubevents.JobContextFromPayload(ghJSON)
+	if c.flagJobFile != "" {
+		if jobJSON, err = readJSONFile(c.flagJobFile); err != nil {
+			return fmt.Errorf("failed to read job file: %w", err)
+		}
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	client := &http.Client{}
+	gh := &githubClient{
+		httpClient: client,
+		baseURL:    c.flagGitHubAPIURL,
+		token:      c.flagGitHubToken,
+	}
+	m := workflowMessage(ctx, gh, ghJSON, jobJSON, c.flagPreviousTag, now(), c.Errf)
+	ns = render.WithRunContext(ns, &render.RunContext{Status: githubevents.StringValue(jobJSON, "status"), GitHub: ghJSON, Job: jobJSON})
+
+	urls := splitWebhookURLs(c.flagWebhookURLs)
+	if len(urls) == 0 {
+		n, ok := ns[c.flagBackend]
+		if !ok {
+			return fmt.Errorf("unknown backend %q, must be one of %q", c.flagBackend, render.Names())
+		}
+		b, err := n.RequestBody(m)
+		if err != nil {
+			return fmt.Errorf("failed to generate message body: %w", err)
+		}
+
+		// Re-encode without escaping HTML, so the Chat formatting is readable.
+		var body any
+		if err := json.Unmarshal(b, &body); err != nil {
+			return fmt.Errorf("failed to format message body: %w", err)
+		}
+		var out bytes.Buffer
+		enc := json.NewEncoder(&out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(body); err != nil {
+			return fmt.Errorf("failed to format message body: %w", err)
+		}
+		c.Outf("%s", strings.TrimSuffix(out.String(), "\n"))
+		return nil
+	}
+
+	messages, err := webhookMessages(urls, m, ns)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, r := range sendToAll(ctx, client, messages, 1) {
+		if r.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.message.name, r.err))
+			continue
+		}
+		c.Outf("%s: sent", r.message.name)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to send to %d of %d destinations: %w", len(errs), len(messages), errors.Join(errs...))
+	}
+	return nil
+}
+
+// githubContext returns the github context for the saved payload. A payload
+// that already is a github context is returned as is, an event payload gets
+// the context reconstructed around it like for GITHUB_EVENT_PATH.
+func (c *ReplayCommand) githubContext(payload []byte) (map[string]any, error) {
+	var probe map[string]any
+	if err := json.Unmarshal(payload, &probe); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling event file: %w", err)
+	}
+	if _, ok := probe[githubevents.EventKey].(map[string]any); ok && githubevents.StringValue(probe, githubevents.EventNameKey) != "" {
+		return probe, nil
+	}
+
+	if c.flagEventName == "" {
+		return nil, fmt.Errorf("--event-name is required for an event payload")
+	}
+	return githubevents.ContextFromPayload(c.flagEventName, c.flagServerURL, payload)
+}
+
+// readJSONFile decodes the JSON object in the file at pth. It returns an empty
+// map if pth is empty.
+func readJSONFile(pth string) (map[string]any, error) {
+	res := map[string]any{}
+	if pth == "" {
+		return res, nil
+	}
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // Caller wraps
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling %s: %w", pth, err)
+	}
+	return res, nil
+}
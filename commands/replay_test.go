@@ -0,0 +1,230 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReplayCommand(t *testing.T) {
+	t.Parallel()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ok.Close)
+
+	dir := t.TempDir()
+	writeFile := func(name, content string) string {
+		pth := filepath.Join(dir, name)
+		if err := os.WriteFile(pth, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return pth
+	}
+
+	issueEvent := writeFile("issue.json", `{
+		"action": "opened",
+		"issue": {"title": "Card looks wrong", "html_url": "https://github.com/test-org/test-repo/issues/1"},
+		"repository": {"full_name": "test-org/test-repo"},
+		"sender": {"login": "octocat"}
+	}`)
+	workflowRunEvent := writeFile("workflow_run.json", `{
+		"action": "completed",
+		"workflow_run": {"name": "ci", "id": 1234567890, "run_attempt": 2, "head_branch": "main"},
+		"repository": {"full_name": "test-org/test-repo"}
+	}`)
+	githubContext := writeFile("github.json", `{
+		"event_name": "push",
+		"repository": "test-org/from-context",
+		"event": {"ref": "refs/heads/main"}
+	}`)
+	jobFile := writeFile("job.json", `{"status": "failure"}`)
+
+	cases := []struct {
+		name    string
+		args    []string
+		wantOut []string
+		wantErr string
+	}{
+		{
+			name:    "event_payload",
+			args:    []string{"--event-name", "issues", issueEvent},
+			wantOut: []string{"A issue is opened", "Card looks wrong", "test-org/test-repo", "octocat"},
+		},
+		{
+			name: "workflow_run_payload",
+			args: []string{"--event-name", "workflow_run", "--job-file", jobFile, workflowRunEvent},
+			wantOut: []string{
+				"GitHub workflow failure", "refs/heads/main", "<b>Attempt: </b> 2",
+				"https://github.com/test-org/test-repo/actions/runs/1234567890",
+			},
+		},
+		{
+			name:    "github_context",
+			args:    []string{githubContext},
+			wantOut: []string{"test-org/from-context"},
+		},
+		{
+			name:    "send",
+			args:    []string{"--event-name", "issues", "--webhook-url", ok.URL, issueEvent},
+			wantOut: []string{"destination 1/1: sent"},
+		},
+		{
+			name:    "missing_event_name",
+			args:    []string{issueEvent},
+			wantErr: "--event-name is required",
+		},
+		{
+			name:    "missing_event_file",
+			args:    []string{filepath.Join(dir, "does-not-exist.json")},
+			wantErr: "failed to read event file",
+		},
+		{
+			name:    "no_arguments",
+			wantErr: "expected 1 argument",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &ReplayCommand{
+				now: func() time.Time { return time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC) },
+			}
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(context.Background(), tc.args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Run() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() got unexpected error: %v", err)
+			}
+			for _, want := range tc.wantOut {
+				if got := stdout.String(); !strings.Contains(got, want) {
+					t.Errorf("Run() got stdout %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayCommand_MatchesWorkflowNotification(t *testing.T) {
+	t.Parallel()
+
+	github := newFakeGitHub(t, `[]`)
+
+	cases := []struct {
+		name     string
+		ghJSON   string
+		job      string
+		wantText string
+	}{
+		{
+			name: "failed_run",
+			ghJSON: `{"repository":"test-org/test-repo","server_url":"https://github.com","event_name":"push",` +
+				`"ref":"refs/heads/main","workflow":"ci","run_id":"1","run_attempt":"1","event":{}}`,
+			job:      `{"status":"failure"}`,
+			wantText: "GitHub workflow failure",
+		},
+		{
+			name: "created_tag",
+			ghJSON: `{"repository":"test-org/test-repo","server_url":"https://github.com","event_name":"create",` +
+				`"event":{"ref":"v1.3.0","ref_type":"tag"}}`,
+			job:      `{"status":"success"}`,
+			wantText: "https://github.com/test-org/test-repo/compare/v1.2.0...v1.3.0",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			now := func() time.Time { return time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC) }
+
+			bodies := make(chan []byte, 1)
+			chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				bodies <- b
+			}))
+			t.Cleanup(chat.Close)
+
+			live := &WorkflowNotificationCommand{now: now}
+			live.SetLookupEnv(cli.MapLookuper(map[string]string{
+				"GITHUB_CONTEXT": tc.ghJSON,
+				"JOB_CONTEXT":    tc.job,
+				"GITHUB_API_URL": github.URL,
+				"GITHUB_TOKEN":   "test-token",
+			}))
+			live.Pipe()
+			if err := live.Run(context.Background(), []string{"--webhook-url", chat.URL}); err != nil {
+				t.Fatal(err)
+			}
+			sent := <-bodies
+			if !strings.Contains(string(sent), tc.wantText) {
+				t.Errorf("Run() sent %s, want it to contain %q", sent, tc.wantText)
+			}
+			var want any
+			if err := json.Unmarshal(sent, &want); err != nil {
+				t.Fatal(err)
+			}
+
+			dir := t.TempDir()
+			for name, content := range map[string]string{"github.json": tc.ghJSON, "job.json": tc.job} {
+				pth := filepath.Join(dir, name)
+				if err := os.WriteFile(pth, []byte(content), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			replay := &ReplayCommand{now: now}
+			replay.SetLookupEnv(cli.MapLookuper(map[string]string{
+				"GITHUB_API_URL": github.URL,
+				"GITHUB_TOKEN":   "test-token",
+			}))
+			_, stdout, _ := replay.Pipe()
+			if err := replay.Run(context.Background(), []string{
+				"--job-file", filepath.Join(dir, "job.json"),
+				filepath.Join(dir, "github.json"),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			var got any
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(want, got); diff != "" {
+				t.Errorf("replayed body got unexpected diff from the sent one (-sent, +replayed):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,361 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+	"gopkg.in/yaml.v3"
+)
+
+// routingConfig is the content of the routing rules file, e.g.
+// .github/chat-notify.yml. It maps conditions on the workflow run to the spaces
+// that should be notified.
+type routingConfig struct {
+	Routes     []*route    `yaml:"routes"`
+	QuietHours *quietHours `yaml:"quiet_hours"`
+}
+
+// route sends a notification to its destinations when all of its conditions
+// match.
+type route struct {
+	Name string          `yaml:"name"`
+	When routeConditions `yaml:"when"`
+
+	// Destinations are the names of the environment variables holding the
+	// webhook URLs, so that the config file can be committed without secrets.
+	Destinations []string `yaml:"destinations"`
+
+	// Template is a text/template rendered with templateData into the text
+	// shown above the card.
+	Template string `yaml:"template"`
+
+	// Mention is added to the text, e.g. <users/all>.
+	Mention string `yaml:"mention"`
+
+	// label is the name of the route in errors and destination names, or its
+	// position in the config when unnamed.
+	label    string
+	template *template.Template
+}
+
+// routeConditions are the conditions of a route. Every non-empty condition has
+// to match, and a condition matches when any of its values does. Branches and
+// tags are glob patterns.
+type routeConditions struct {
+	Events    []string          `yaml:"events"`
+	Branches  []string          `yaml:"branches"`
+	Tags      []string          `yaml:"tags"`
+	Statuses  []string          `yaml:"statuses"`
+	Workflows []string          `yaml:"workflows"`
+	Labels    []string          `yaml:"labels"`
+	Matrix    map[string]string `yaml:"matrix"`
+}
+
+// routeInput holds the values of a workflow run the routes are matched against.
+type routeInput struct {
+	eventName string
+	// branch is the branch of the ref, and branches are the ones matched by
+	// routes, which are the head and base branches for pull requests.
+	branch   string
+	branches []string
+	tag      string
+	status   string
+	workflow string
+	labels   []string
+	matrix   map[string]any
+}
+
+// templateData is the data route templates are rendered with.
+type templateData struct {
+	Title     string
+	Subtitle  string
+	Repo      string
+	Ref       string
+	Actor     string
+	EventName string
+	Status    string
+	URL       string
+	Mention   string
+}
+
+// loadRoutingConfig reads and validates the routing rules file.
+func loadRoutingConfig(pth string) (*routingConfig, error) {
+	b, err := os.ReadFile(pth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing config: %w", err)
+	}
+	return parseRoutingConfig(b)
+}
+
+// parseRoutingConfig parses and validates the routing rules. Unknown keys are
+// rejected so that typos don't silently disable a condition.
+func parseRoutingConfig(b []byte) (*routingConfig, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true)
+
+	var cfg routingConfig
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse routing config: %w", err)
+	}
+
+	var merr error
+	for i, r := range cfg.Routes {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		r.label = name
+
+		if len(r.Destinations) == 0 {
+			merr = errors.Join(merr, fmt.Errorf("route %s: at least one destination is required", name))
+		}
+		for _, p := range slices.Concat(r.When.Branches, r.When.Tags) {
+			if !isValidPattern(p) {
+				merr = errors.Join(merr, fmt.Errorf("route %s: invalid pattern %q", name, p))
+			}
+		}
+		if r.Template != "" {
+			tmpl, err := template.New(name).Option("missingkey=error").Parse(r.Template)
+			if err != nil {
+				merr = errors.Join(merr, fmt.Errorf("route %s: invalid template: %w", name, err))
+			}
+			r.template = tmpl
+		}
+	}
+	if cfg.QuietHours != nil {
+		if err := cfg.QuietHours.validate(); err != nil {
+			merr = errors.Join(merr, fmt.Errorf("quiet_hours: %w", err))
+		}
+	}
+	if merr != nil {
+		return nil, fmt.Errorf("invalid routing config: %w", merr)
+	}
+	return &cfg, nil
+}
+
+// newRouteInput returns the values the routes are matched against.
+func newRouteInput(ghJSON, jobJSON, matrixJSON map[string]any) *routeInput {
+	ref := githubevents.StringValue(ghJSON, githubevents.RefKey)
+	event := githubevents.MapValue(ghJSON, githubevents.EventKey)
+
+	// Labels of the issue or pull request, plus the label that was just added
+	// for labeled events.
+	var labels []string
+	labels = append(labels, githubevents.ListStringValues(githubevents.MapValue(event, "issue"), "labels", "name")...)
+	labels = append(labels, githubevents.ListStringValues(githubevents.MapValue(event, "pull_request"), "labels", "name")...)
+	if v := githubevents.StringValue(githubevents.MapValue(event, "label"), "name"); v != "" && !slices.Contains(labels, v) {
+		labels = append(labels, v)
+	}
+
+	branch, ok := strings.CutPrefix(ref, "refs/heads/")
+	if !ok {
+		branch = ""
+	}
+	tag, ok := strings.CutPrefix(ref, "refs/tags/")
+	if !ok {
+		tag = ""
+	}
+
+	// The ref of pull requests is refs/pull/N/merge, so their head and base
+	// branches are matched instead.
+	var branches []string
+	if branch != "" {
+		branches = append(branches, branch)
+	}
+	pr := githubevents.MapValue(event, "pull_request")
+	for _, b := range []string{
+		cmp.Or(githubevents.StringValue(ghJSON, "head_ref"), githubevents.StringValue(githubevents.MapValue(pr, "head"), "ref")),
+		cmp.Or(githubevents.StringValue(ghJSON, "base_ref"), githubevents.StringValue(githubevents.MapValue(pr, "base"), "ref")),
+	} {
+		if b != "" && !slices.Contains(branches, b) {
+			branches = append(branches, b)
+		}
+	}
+
+	return &routeInput{
+		eventName: githubevents.StringValue(ghJSON, githubevents.EventNameKey),
+		branch:    branch,
+		branches:  branches,
+		tag:       tag,
+		status:    githubevents.StringValue(jobJSON, "status"),
+		workflow:  githubevents.StringValue(ghJSON, "workflow"),
+		labels:    labels,
+		matrix:    matrixJSON,
+	}
+}
+
+// matches returns true if every condition of the route matches in.
+func (r *route) matches(in *routeInput) bool {
+	w := r.When
+
+	if len(w.Events) > 0 && !slices.Contains(w.Events, in.eventName) {
+		return false
+	}
+	if len(w.Branches) > 0 && !slices.ContainsFunc(in.branches, func(b string) bool {
+		return matchAny(w.Branches, b)
+	}) {
+		return false
+	}
+	if len(w.Tags) > 0 && (in.tag == "" || !matchAny(w.Tags, in.tag)) {
+		return false
+	}
+	if len(w.Statuses) > 0 && !slices.Contains(w.Statuses, in.status) {
+		return false
+	}
+	if len(w.Workflows) > 0 && !slices.Contains(w.Workflows, in.workflow) {
+		return false
+	}
+	if len(w.Labels) > 0 && !slices.ContainsFunc(w.Labels, func(l string) bool {
+		return slices.Contains(in.labels, l)
+	}) {
+		return false
+	}
+	for k, want := range w.Matrix {
+		if got, ok := in.matrix[k]; !ok || fmt.Sprint(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// text renders the text of the message sent by the route. Without a template
+// the text is only the mention, if any. The mention is prepended to the text of
+// templates not rendering it.
+func (r *route) text(m *cards.Message, status string) (string, error) {
+	if r.template == nil {
+		return r.Mention, nil
+	}
+
+	var b strings.Builder
+	if err := r.template.Execute(&b, &templateData{
+		Title:     m.Title,
+		Subtitle:  m.Subtitle,
+		Repo:      m.Repo,
+		Ref:       m.Ref,
+		Actor:     m.Actor,
+		EventName: m.EventName,
+		Status:    status,
+		URL:       m.ClickURL,
+		Mention:   r.Mention,
+	}); err != nil {
+		return "", fmt.Errorf("failed to render template of route %s: %w", r.label, err)
+	}
+	text := b.String()
+	if r.Mention != "" && !strings.Contains(text, r.Mention) {
+		text = r.Mention + " " + text
+	}
+	return text, nil
+}
+
+// routeMessages returns the messages of every route matching in. Destination
+// URLs are looked up with getEnv, and a URL targeted by several matching routes
+// only gets the message of the first one. When noMentions is set, mentions are
+// removed from the text of the messages. Messages are rendered for the backend
+// of their URL among ns.
+func routeMessages(cfg *routingConfig, m *cards.Message, in *routeInput, getEnv func(string) string, noMentions bool, ns map[string]render.Notifier) ([]*outgoingMessage, error) {
+	var messages []*outgoingMessage
+	seen := make(map[string]struct{})
+	for _, r := range cfg.Routes {
+		if !r.matches(in) {
+			continue
+		}
+
+		text, err := r.text(m, in.status)
+		if err != nil {
+			return nil, err
+		}
+		if noMentions {
+			text = stripMentions(text)
+		}
+		rm := *m
+		rm.Text = text
+
+		for _, dest := range r.Destinations {
+			urls := splitWebhookURLs([]string{getEnv(dest)})
+			if len(urls) == 0 {
+				return nil, fmt.Errorf("route %s: environment var %s not set", r.label, dest)
+			}
+			for _, url := range urls {
+				if _, ok := seen[url]; ok {
+					continue
+				}
+				seen[url] = struct{}{}
+
+				msg, err := newOutgoingMessage(fmt.Sprintf("route %s (%s)", r.label, dest), url, &rm, ns)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, msg)
+			}
+		}
+	}
+	return messages, nil
+}
+
+// isValidPattern returns true if p is a valid glob pattern.
+func isValidPattern(p string) bool {
+	for _, part := range strings.Split(p, "**") {
+		if _, err := path.Match(part, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny returns true if s matches any of the glob patterns.
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if matchPattern(p, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern returns true if s matches the glob pattern p. Like in the
+// branch filters of GitHub Actions, * doesn't match /, and ** matches any
+// characters including /, e.g. release/** matches release/1.0/rc.1.
+func matchPattern(p, s string) bool {
+	before, after, ok := strings.Cut(p, "**")
+	if !ok {
+		// Patterns are validated when the config is loaded.
+		matched, _ := path.Match(p, s)
+		return matched
+	}
+
+	for i := 0; i <= len(s); i++ {
+		if matched, _ := path.Match(before, s[:i]); !matched {
+			continue
+		}
+		for j := i; j <= len(s); j++ {
+			if matchPattern(after, s[j:]) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,419 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseRoutingConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			in: `
+routes:
+  - name: 'failures'
+    when:
+      events: ['push']
+      branches: ['main', 'release/*']
+      statuses: ['failure']
+      matrix:
+        os: 'ubuntu-latest'
+    destinations: ['ONCALL_WEBHOOK_URL']
+    mention: '<users/all>'
+    template: '{{ .Mention }} {{ .Title }}'
+`,
+		},
+		{
+			name: "unknown_key",
+			in: `
+routes:
+  - name: 'failures'
+    when:
+      branch: ['main']
+    destinations: ['ONCALL_WEBHOOK_URL']
+`,
+			wantErr: "field branch not found",
+		},
+		{
+			name: "missing_destinations",
+			in: `
+routes:
+  - name: 'failures'
+`,
+			wantErr: "route failures: at least one destination is required",
+		},
+		{
+			name: "invalid_pattern",
+			in: `
+routes:
+  - when:
+      tags: ['v[']
+    destinations: ['ONCALL_WEBHOOK_URL']
+`,
+			wantErr: `route #1: invalid pattern "v["`,
+		},
+		{
+			name: "invalid_template",
+			in: `
+routes:
+  - name: 'failures'
+    destinations: ['ONCALL_WEBHOOK_URL']
+    template: '{{ .Title'
+`,
+			wantErr: "route failures: invalid template",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseRoutingConfig([]byte(tc.in))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("parseRoutingConfig() got unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseRoutingConfig() got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRouteMatches(t *testing.T) {
+	t.Parallel()
+
+	in := newRouteInput(
+		map[string]any{
+			"ref":        "refs/heads/release/1.0",
+			"workflow":   "ci",
+			"event_name": "issues",
+			"event": map[string]any{
+				"issue": map[string]any{
+					"labels": []any{
+						map[string]any{"name": "bug"},
+					},
+				},
+				"label": map[string]any{
+					"name": "urgent",
+				},
+			},
+		},
+		map[string]any{
+			"status": "failure",
+		},
+		map[string]any{
+			"os":      "ubuntu-latest",
+			"version": float64(20),
+		},
+	)
+
+	cases := []struct {
+		name string
+		when routeConditions
+		want bool
+	}{
+		{
+			name: "no_conditions",
+			want: true,
+		},
+		{
+			name: "all_conditions",
+			when: routeConditions{
+				Events:    []string{"push", "issues"},
+				Branches:  []string{"main", "release/*"},
+				Statuses:  []string{"failure"},
+				Workflows: []string{"ci"},
+				Labels:    []string{"urgent"},
+				Matrix:    map[string]string{"os": "ubuntu-latest", "version": "20"},
+			},
+			want: true,
+		},
+		{
+			name: "event_mismatch",
+			when: routeConditions{Events: []string{"push"}},
+		},
+		{
+			name: "branch_mismatch",
+			when: routeConditions{Branches: []string{"main"}},
+		},
+		{
+			name: "tag_on_branch",
+			when: routeConditions{Tags: []string{"*"}},
+		},
+		{
+			name: "status_mismatch",
+			when: routeConditions{Statuses: []string{"success"}},
+		},
+		{
+			name: "label_mismatch",
+			when: routeConditions{Labels: []string{"docs"}},
+		},
+		{
+			name: "matrix_mismatch",
+			when: routeConditions{Matrix: map[string]string{"os": "windows-latest"}},
+		},
+		{
+			name: "matrix_missing_key",
+			when: routeConditions{Matrix: map[string]string{"arch": "arm64"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &route{When: tc.when}
+			if got, want := r.matches(in), tc.want; got != want {
+				t.Errorf("matches() got %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestRouteMatches_PullRequest(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		ghJSON   map[string]any
+		branches []string
+		want     bool
+	}{
+		{
+			name: "base_ref",
+			ghJSON: map[string]any{
+				"ref":      "refs/pull/1/merge",
+				"head_ref": "feature",
+				"base_ref": "main",
+			},
+			branches: []string{"main"},
+			want:     true,
+		},
+		{
+			name: "head_ref",
+			ghJSON: map[string]any{
+				"ref":      "refs/pull/1/merge",
+				"head_ref": "release/1.0",
+				"base_ref": "main",
+			},
+			branches: []string{"release/*"},
+			want:     true,
+		},
+		{
+			name: "payload",
+			ghJSON: map[string]any{
+				"event": map[string]any{
+					"pull_request": map[string]any{
+						"head": map[string]any{"ref": "feature"},
+						"base": map[string]any{"ref": "main"},
+					},
+				},
+			},
+			branches: []string{"main"},
+			want:     true,
+		},
+		{
+			name: "mismatch",
+			ghJSON: map[string]any{
+				"ref":      "refs/pull/1/merge",
+				"head_ref": "feature",
+				"base_ref": "develop",
+			},
+			branches: []string{"main"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &route{When: routeConditions{Branches: tc.branches}}
+			if got, want := r.matches(newRouteInput(tc.ghJSON, nil, nil)), tc.want; got != want {
+				t.Errorf("matches() got %t, want %t", got, want)
+			}
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		pattern string
+		s       string
+		want    bool
+	}{
+		{pattern: "main", s: "main", want: true},
+		{pattern: "release/*", s: "release/1.0", want: true},
+		{pattern: "release/*", s: "release/1.0/rc.1", want: false},
+		{pattern: "feature/*/*", s: "feature/a/b", want: true},
+		{pattern: "release/**", s: "release/1.0/rc.1", want: true},
+		{pattern: "release/**", s: "release/", want: true},
+		{pattern: "release/**", s: "releases/1.0", want: false},
+		{pattern: "**/hotfix", s: "team/a/hotfix", want: true},
+		{pattern: "feature**", s: "feature/a/b", want: true},
+		{pattern: "a/**/b/*", s: "a/x/y/b/c", want: true},
+		{pattern: "a/**/b/*", s: "a/x/y/b/c/d", want: false},
+	}
+
+	for _, tc := range cases {
+		if got := matchPattern(tc.pattern, tc.s); got != tc.want {
+			t.Errorf("matchPattern(%q, %q) got %t, want %t", tc.pattern, tc.s, got, tc.want)
+		}
+	}
+}
+
+func TestRouteText(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		template string
+		mention  string
+		want     string
+	}{
+		{
+			name:    "mention_only",
+			mention: "<users/all>",
+			want:    "<users/all>",
+		},
+		{
+			name:     "template_with_mention",
+			template: "{{ .Repo }} failed {{ .Mention }}",
+			mention:  "<users/all>",
+			want:     "test-repository failed <users/all>",
+		},
+		{
+			name:     "template_without_mention",
+			template: "{{ .Repo }} failed",
+			mention:  "<users/all>",
+			want:     "<users/all> test-repository failed",
+		},
+		{
+			name:     "template_no_mention_set",
+			template: "{{ .Repo }} failed",
+			want:     "test-repository failed",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &route{Name: "test", Mention: tc.mention}
+			if tc.template != "" {
+				r.template = template.Must(template.New("test").Parse(tc.template))
+			}
+			got, err := r.text(&cards.Message{Repo: "test-repository"}, "failure")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("text() got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRouteMessages(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := parseRoutingConfig([]byte(`
+routes:
+  - name: 'oncall'
+    when:
+      statuses: ['failure']
+    destinations: ['ONCALL_WEBHOOK_URL']
+    mention: '<users/all>'
+    template: '{{ .Mention }} {{ .Repo }} is {{ .Status }}'
+  - name: 'team'
+    destinations: ['TEAM_WEBHOOK_URL', 'ONCALL_WEBHOOK_URL']
+  - name: 'releases'
+    when:
+      events: ['release']
+    destinations: ['RELEASE_WEBHOOK_URL']
+  - when:
+      events: ['deployment']
+    destinations: ['DEPLOY_WEBHOOK_URL']
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"ONCALL_WEBHOOK_URL": "https://oncall",
+		"TEAM_WEBHOOK_URL":   "https://team-a\nhttps://team-b",
+	}
+	in := &routeInput{status: "failure"}
+	m := &cards.Message{Repo: "test-repository"}
+
+	messages, err := routeMessages(cfg, m, in, func(k string) string { return env[k] }, false, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type sent struct {
+		Name string
+		URL  string
+		Text string
+	}
+	got := make([]sent, 0, len(messages))
+	for _, msg := range messages {
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.Unmarshal(msg.body, &body); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, sent{Name: msg.name, URL: msg.url, Text: body.Text})
+	}
+
+	want := []sent{
+		{Name: "route oncall (ONCALL_WEBHOOK_URL)", URL: "https://oncall", Text: "<users/all> test-repository is failure"},
+		{Name: "route team (TEAM_WEBHOOK_URL)", URL: "https://team-a"},
+		{Name: "route team (TEAM_WEBHOOK_URL)", URL: "https://team-b"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("routeMessages got unexpected diff (-want, +got):\n%s", diff)
+	}
+
+	if _, err := routeMessages(cfg, m, &routeInput{eventName: "release"}, func(k string) string { return env[k] }, false, render.Notifiers()); err == nil ||
+		!strings.Contains(err.Error(), "route releases: environment var RELEASE_WEBHOOK_URL not set") {
+		t.Errorf("routeMessages() got error %v, want missing environment var error", err)
+	}
+
+	// Unnamed routes are referred to by their position.
+	if _, err := routeMessages(cfg, m, &routeInput{eventName: "deployment"}, func(k string) string { return env[k] }, false, render.Notifiers()); err == nil ||
+		!strings.Contains(err.Error(), "route #4: environment var DEPLOY_WEBHOOK_URL not set") {
+		t.Errorf("routeMessages() got error %v, want missing environment var error of route #4", err)
+	}
+}
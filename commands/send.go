@@ -0,0 +1,185 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+// outgoingMessage is a request body to send to one destination.
+type outgoingMessage struct {
+	// name identifies the destination in logs, webhook URLs contain secrets
+	// and must never be logged. Errors of the backends only report the host.
+	name string
+	url  string
+	body []byte
+	// notifier is the backend the body was rendered for, Google Chat if nil.
+	notifier render.Notifier
+}
+
+// backend returns the backend of the message.
+func (msg *outgoingMessage) backend() render.Notifier {
+	if msg.notifier == nil {
+		return render.GoogleChat{}
+	}
+	return msg.notifier
+}
+
+// deliveryResult is the outcome of sending a message to one destination.
+type deliveryResult struct {
+	message    *outgoingMessage
+	statusCode int
+	// sent is the message created by Chat, nil if the message was not sent.
+	sent *chat.Message
+	err  error
+}
+
+// splitWebhookURLs flattens the values of the repeatable webhook-url flag,
+// each of which may hold several newline separated URLs as provided by a
+// multi-line action input.
+func splitWebhookURLs(values []string) []string {
+	var res []string
+	for _, v := range values {
+		for _, line := range strings.Split(v, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				res = append(res, line)
+			}
+		}
+	}
+	return res
+}
+
+// stringsVar adds a repeatable string flag to f. Unlike StringSliceVar, the
+// values are not split on commas, which URLs and header values may contain.
+// Values given with the flag replace the value of the environment var.
+func stringsVar(f *cli.FlagSection, i *cli.StringSliceVar) {
+	v := &stringsValue{}
+	cli.Flag(f, &cli.Var[[]string]{
+		Name:    i.Name,
+		Usage:   i.Usage,
+		Example: i.Example,
+		EnvVar:  i.EnvVar,
+		Target:  i.Target,
+		Parser: func(s string) ([]string, error) {
+			if strings.TrimSpace(s) == "" {
+				return nil, nil
+			}
+			return []string{s}, nil
+		},
+		Printer: func(vals []string) string {
+			return strings.Join(vals, "\n")
+		},
+		Setter: v.set,
+	})
+	// Values set from now on are given with the flag.
+	v.registered = true
+}
+
+// stringsValue tracks where the values of a stringsVar flag come from, so that
+// the values given with the flag replace the default or environment var rather
+// than being added to it.
+type stringsValue struct {
+	registered bool
+	given      bool
+}
+
+// set is the setter of a stringsVar flag. Before the flag is registered, it
+// sets the default. The first value given with the flag replaces it, and the
+// next ones are appended.
+func (v *stringsValue) set(cur *[]string, val []string) {
+	switch {
+	case !v.registered:
+		*cur = val
+	case !v.given:
+		v.given = true
+		*cur = val
+	default:
+		*cur = append(*cur, val...)
+	}
+}
+
+// webhookMessages returns the messages sending m to every URL, rendered for
+// the backend of each URL among ns.
+func webhookMessages(urls []string, m *cards.Message, ns map[string]render.Notifier) ([]*outgoingMessage, error) {
+	messages := make([]*outgoingMessage, 0, len(urls))
+	for i, url := range urls {
+		msg, err := newOutgoingMessage(fmt.Sprintf("destination %d/%d", i+1, len(urls)), url, m, ns)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// sendToAll sends every message concurrently, with at most concurrency
+// requests in flight, and returns one result per message in the same order.
+func sendToAll(ctx context.Context, client *http.Client, messages []*outgoingMessage, concurrency int) []*deliveryResult {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]*deliveryResult, len(messages))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, msg := range messages {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			statusCode, sent, err := msg.backend().Send(ctx, client, msg.url, msg.body)
+			results[i] = &deliveryResult{
+				message:    msg,
+				statusCode: statusCode,
+				sent:       sent,
+				err:        err,
+			}
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// newOutgoingMessage returns the message rendering m for the backend of the
+// webhook url raw among ns.
+func newOutgoingMessage(name, raw string, m *cards.Message, ns map[string]render.Notifier) (*outgoingMessage, error) {
+	n, u, err := render.ParseWebhookURL(raw, ns)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	body, err := n.RequestBody(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: failed to generate %s message body: %w", name, n.Name(), err)
+	}
+	return &outgoingMessage{
+		name:     name,
+		url:      u,
+		body:     body,
+		notifier: n,
+	}, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestSplitWebhookURLs(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{
+			name:   "empty",
+			values: nil,
+			want:   nil,
+		},
+		{
+			name:   "repeated_flag",
+			values: []string{"https://a", "https://b"},
+			want:   []string{"https://a", "https://b"},
+		},
+		{
+			name:   "newline_separated",
+			values: []string{"https://a\n\n  https://b  \n", "https://c"},
+			want:   []string{"https://a", "https://b", "https://c"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, splitWebhookURLs(tc.values)); diff != "" {
+				t.Errorf("splitWebhookURLs got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStringsVar(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want []string
+	}{
+		{
+			name: "comma_in_value",
+			args: []string{"-webhook-url", "https://a/?b=1,2"},
+			want: []string{"https://a/?b=1,2"},
+		},
+		{
+			name: "repeated_flag",
+			args: []string{"-webhook-url", "https://a", "-webhook-url", "https://b\nhttps://c"},
+			want: []string{"https://a", "https://b\nhttps://c"},
+		},
+		{
+			name: "env",
+			env:  map[string]string{"WEBHOOK_URL": "https://a/?b=1,2"},
+			want: []string{"https://a/?b=1,2"},
+		},
+		{
+			name: "flag_replaces_env",
+			env:  map[string]string{"WEBHOOK_URL": "https://a"},
+			args: []string{"-webhook-url", "https://b"},
+			want: []string{"https://b"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got []string
+			set := cli.NewFlagSet(cli.WithLookupEnv(cli.MapLookuper(tc.env)))
+			stringsVar(set.NewSection("OPTIONS"), &cli.StringSliceVar{
+				Name:   "webhook-url",
+				EnvVar: "WEBHOOK_URL",
+				Target: &got,
+			})
+			if err := set.Parse(tc.args); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("values got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestStringsVar_RegisteredTwice(t *testing.T) {
+	t.Parallel()
+
+	var got []string
+	for _, args := range [][]string{{"-webhook-url", "https://a"}, {"-webhook-url", "https://b"}} {
+		set := cli.NewFlagSet()
+		stringsVar(set.NewSection("OPTIONS"), &cli.StringSliceVar{
+			Name:   "webhook-url",
+			Target: &got,
+		})
+		if err := set.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if diff := cmp.Diff([]string{"https://b"}, got); diff != "" {
+		t.Errorf("values got unexpected diff (-want, +got):\n%s", diff)
+	}
+}
+
+func TestSendToAll(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, maxInFlight atomic.Int32
+	handler := func(status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			w.WriteHeader(status)
+		}
+	}
+
+	ok := httptest.NewServer(handler(http.StatusOK))
+	t.Cleanup(ok.Close)
+	broken := httptest.NewServer(handler(http.StatusInternalServerError))
+	t.Cleanup(broken.Close)
+
+	urls := []string{ok.URL, broken.URL, ok.URL, ok.URL}
+	messages, err := webhookMessages(urls, &cards.Message{}, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := sendToAll(context.Background(), ok.Client(), messages, 2)
+
+	if got, want := len(results), len(urls); got != want {
+		t.Fatalf("got %d results, want %d", got, want)
+	}
+	for i, r := range results {
+		if got, want := r.message, messages[i]; got != want {
+			t.Errorf("results[%d].message got %q, want %q", i, got.name, want.name)
+		}
+		if got, want := r.err != nil, urls[i] == broken.URL; got != want {
+			t.Errorf("results[%d] got error %v, want error %t", i, r.err, want)
+		}
+	}
+	if got := maxInFlight.Load(); got > 2 {
+		t.Errorf("got %d requests in flight, want at most 2", got)
+	}
+}
+
+func TestSendToAll_Backends(t *testing.T) {
+	t.Parallel()
+
+	bodies := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(b, &body)
+		bodies <- body
+		// Discord responds with 204 No Content.
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	messages, err := webhookMessages([]string{"discord+" + srv.URL}, &cards.Message{Title: "GitHub workflow failed"}, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := sendToAll(context.Background(), srv.Client(), messages, 1)
+	if err := results[0].err; err != nil {
+		t.Fatalf("got error %v", err)
+	}
+	if _, ok := (<-bodies)["embeds"]; !ok {
+		t.Errorf("got request body without embeds")
+	}
+}
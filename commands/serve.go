@@ -0,0 +1,390 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+// maxPayloadSize is the maximum size of a webhook delivery, GitHub caps
+// payloads at 25 MB.
+const maxPayloadSize = 25 << 20
+
+// relayedEvents are the events relayed to Chat with the action they are
+// relayed for, or "" for any action. Runs, jobs and checks only have a
+// conclusion once they are completed, other events have no card.
+var relayedEvents = map[string]string{
+	"issues":             "",
+	"discussion":         "",
+	"discussion_comment": "",
+	"create":             "",
+	"delete":             "",
+	"release":            "",
+	"workflow_run":       "completed",
+	"workflow_job":       "completed",
+	"check_suite":        "completed",
+	"check_run":          "completed",
+}
+
+// ServeCommand relays GitHub webhook deliveries to Chat spaces, e.g. for an
+// organization webhook, so that workflows don't need a notification step.
+type ServeCommand struct {
+	cli.BaseCommand
+	flagPort        string
+	flagSecret      string
+	flagWebhookURLs []string
+	flagConfig      string
+	flagServerURL   string
+	flagConcurrency int
+	flagOutboxDir   string
+	jsonFlags       jsonWebhookFlags
+
+	// now is overridden in tests.
+	now func() time.Time
+}
+
+func (c *ServeCommand) Desc() string {
+	return "Relay GitHub webhook deliveries to Google Chat spaces"
+}
+
+func (c *ServeCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  The serve command listens for GitHub webhook deliveries, verifies their
+  signature and sends the card for the event to Google Chat spaces.
+`
+}
+
+func (c *ServeCommand) Flags() *cli.FlagSet {
+	set := c.NewFlagSet()
+
+	f := set.NewSection("COMMAND OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:    "port",
+		Example: "8080",
+		Default: "8080",
+		EnvVar:  "PORT",
+		Target:  &c.flagPort,
+		Usage:   `Port to listen on.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "webhook-secret",
+		EnvVar: "GITHUB_WEBHOOK_SECRET",
+		Target: &c.flagSecret,
+		Usage:  `Secret of the GitHub webhook, used to verify deliveries.`,
+	})
+
+	stringsVar(f, &cli.StringSliceVar{
+		Name:    "webhook-url",
+		Example: "https://chat.googleapis.com/v1/spaces/<SPACE_ID>/messages?key=<KEY>&token=<TOKEN>",
+		EnvVar:  "WEBHOOK_URL",
+		Target:  &c.flagWebhookURLs,
+		Usage: `Webhook url of the Chat space to notify. Can be repeated, or ` +
+			`hold several newline separated urls. Used when no route matches.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "config",
+		Example: "chat-notify.yml",
+		Target:  &c.flagConfig,
+		Usage:   `Routing rules choosing the spaces to notify based on the event.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "server-url",
+		Example: "https://github.com",
+		Default: "https://github.com",
+		Target:  &c.flagServerURL,
+		Usage:   `URL of the GitHub server sending the deliveries.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "concurrency",
+		Example: "4",
+		Default: 4,
+		Target:  &c.flagConcurrency,
+		Usage:   `Maximum number of destinations notified at the same time.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "outbox-dir",
+		Example: "/var/lib/send-google-chat-webhook",
+		Target:  &c.flagOutboxDir,
+		Usage: `Directory of the outbox. When set, messages are persisted ` +
+			`before they are sent, and retried in order per destination until ` +
+			`they are sent. Redeliveries of the same X-GitHub-Delivery id are ` +
+			`ignored, and the queue depth is reported at /status.`,
+	})
+
+	c.jsonFlags.register(set)
+
+	return set
+}
+
+func (c *ServeCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	args = f.Args()
+	if len(args) != 0 {
+		return fmt.Errorf("expected 0 arguments, got %q", args)
+	}
+
+	ns, err := c.jsonFlags.notifiers()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	var ob *outbox
+	if c.flagOutboxDir != "" {
+		if ob, err = openOutbox(c.flagOutboxDir, client, ns); err != nil {
+			return err
+		}
+		ob.logf = func(format string, args ...any) { c.Outf("outbox: "+format, args...) }
+
+		workersCtx, cancel := context.WithCancel(ctx)
+		defer func() {
+			cancel()
+			if err := ob.close(); err != nil {
+				c.Errf("%s", err)
+			}
+		}()
+		ob.start(workersCtx)
+	}
+
+	h, err := c.handler(client, ob, ns)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr:              net.JoinHostPort("", c.flagPort),
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		c.Outf("listening on %s", srv.Addr)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	return nil
+}
+
+// handler returns the handler of webhook deliveries. It validates the flags
+// and loads the routing config once, so that bad configuration fails at
+// startup rather than on every delivery. With an outbox, messages are enqueued
+// rather than sent right away.
+func (c *ServeCommand) handler(client *http.Client, ob *outbox, ns map[string]render.Notifier) (http.Handler, error) {
+	if c.flagSecret == "" {
+		return nil, fmt.Errorf("a webhook secret is required")
+	}
+
+	var cfg *routingConfig
+	if c.flagConfig != "" {
+		var err error
+		if cfg, err = loadRoutingConfig(c.flagConfig); err != nil {
+			return nil, err
+		}
+	}
+	urls := splitWebhookURLs(c.flagWebhookURLs)
+	if len(urls) == 0 && cfg == nil {
+		return nil, fmt.Errorf("at least one webhook url or a routing config is required")
+	}
+
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /", func(w http.ResponseWriter, r *http.Request) {
+		delivery := r.Header.Get("X-GitHub-Delivery")
+
+		payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+		if err != nil {
+			http.Error(w, "failed to read payload", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if !validSignature(c.flagSecret, payload, r.Header.Get("X-Hub-Signature-256")) {
+			c.Errf("delivery %s: invalid signature", delivery)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		eventName := r.Header.Get("X-GitHub-Event")
+		switch eventName {
+		case "":
+			http.Error(w, "missing X-GitHub-Event header", http.StatusBadRequest)
+			return
+		case "ping":
+			fmt.Fprintln(w, "pong")
+			return
+		}
+
+		ghJSON, err := githubevents.ContextFromPayload(eventName, c.flagServerURL, payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		action, ok := relayedEvents[eventName]
+		if !ok || (action != "" && githubevents.StringValue(githubevents.MapValue(ghJSON, githubevents.EventKey), "action") != action) {
+			c.Outf("delivery %s: %s event not relayed", delivery, eventName)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		jobJSON := githubevents.JobContextFromPayload(ghJSON)
+		deliveredAt := now()
+		m := githubevents.MessageContent(ghJSON, jobJSON, deliveredAt)
+		rc := &render.RunContext{Status: githubevents.StringValue(jobJSON, "status"), GitHub: ghJSON, Job: jobJSON}
+
+		messages, err := c.messages(cfg, urls, m, ghJSON, jobJSON, deliveredAt, render.WithRunContext(ns, rc))
+		if err != nil {
+			c.Errf("delivery %s: %s", delivery, err)
+			http.Error(w, "failed to render message", http.StatusInternalServerError)
+			return
+		}
+		// Redeliveries keep their id, so Chat collapses them as well.
+		if err := setIdempotencyKey(messages, delivery); err != nil {
+			c.Errf("delivery %s: %s", delivery, err)
+			http.Error(w, "failed to render message", http.StatusInternalServerError)
+			return
+		}
+		if len(messages) == 0 {
+			c.Outf("delivery %s: nothing to send", delivery)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if ob != nil {
+			enqueued, err := ob.enqueue(delivery, messages)
+			if err != nil {
+				c.Errf("delivery %s: %s", delivery, err)
+				http.Error(w, "failed to enqueue message", http.StatusInternalServerError)
+				return
+			}
+			if !enqueued {
+				c.Outf("delivery %s: duplicate, ignored", delivery)
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		var errs []error
+		for _, res := range sendToAll(r.Context(), client, messages, c.flagConcurrency) {
+			if res.err != nil {
+				c.Errf("delivery %s: %s: failed: %s", delivery, res.message.name, res.err)
+				errs = append(errs, res.err)
+				continue
+			}
+			c.Outf("delivery %s: %s: sent", delivery, res.message.name)
+		}
+		if len(errs) > 0 {
+			http.Error(w, fmt.Sprintf("failed to send to %d of %d destinations", len(errs), len(messages)), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if ob != nil {
+		mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(ob.status()); err != nil {
+				c.Errf("failed to write status: %s", err)
+			}
+		})
+	}
+	return mux, nil
+}
+
+// messages returns the messages for the event, routed with cfg when set and
+// otherwise, or when no route matches, sent to every url. Like for workflows,
+// the quiet hours of cfg apply at now. Messages are rendered for the backend of
+// their URL among ns.
+func (c *ServeCommand) messages(cfg *routingConfig, urls []string, m *cards.Message, ghJSON, jobJSON map[string]any, now time.Time, ns map[string]render.Notifier) ([]*outgoingMessage, error) {
+	if cfg != nil {
+		in := newRouteInput(ghJSON, jobJSON, map[string]any{})
+
+		decision := quietHoursDeliver
+		if cfg.QuietHours != nil {
+			decision = cfg.QuietHours.decide(now, githubevents.IsFailedStatus(in.status), in.branch)
+		}
+		if decision == quietHoursSuppress {
+			return nil, nil
+		}
+
+		messages, err := routeMessages(cfg, m, in, c.GetEnv, decision == quietHoursStripMentions, ns)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil
+	}
+
+	return webhookMessages(urls, m, ns)
+}
+
+// validSignature reports whether signature, the X-Hub-Signature-256 header of
+// a delivery, is the HMAC-SHA256 of the payload with secret.
+func validSignature(secret string, payload []byte, signature string) bool {
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	gotMAC, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(gotMAC, mac.Sum(nil))
+}
@@ -0,0 +1,320 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+func signPayload(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		signature string
+		want      bool
+	}{
+		{
+			name:      "valid",
+			signature: signPayload("test-secret", `{}`),
+			want:      true,
+		},
+		{
+			name:      "wrong_secret",
+			signature: signPayload("other-secret", `{}`),
+		},
+		{
+			name:      "sha1",
+			signature: strings.Replace(signPayload("test-secret", `{}`), "sha256=", "sha1=", 1),
+		},
+		{
+			name:      "not_hex",
+			signature: "sha256=zz",
+		},
+		{
+			name: "missing",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := validSignature("test-secret", []byte(`{}`), tc.signature); got != tc.want {
+				t.Errorf("validSignature() got %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestServeCommand_Handler(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var received []string
+	chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(b))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(chat.Close)
+
+	cmd := &ServeCommand{
+		flagSecret:      "test-secret",
+		flagWebhookURLs: []string{chat.URL},
+		flagServerURL:   "https://github.com",
+		flagConcurrency: 1,
+		now:             func() time.Time { return time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC) },
+	}
+	cmd.Pipe()
+	h, err := cmd.handler(chat.Client(), nil, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuePayload := `{"action":"opened","issue":{"title":"Relayed issue"},"repository":{"full_name":"test-org/test-repo"}}`
+	runPayload := func(action, conclusion string) string {
+		return fmt.Sprintf(`{"action":%q,"workflow_run":{"id":1,"name":"Relayed workflow","head_branch":"main","conclusion":%q},"repository":{"full_name":"test-org/test-repo"}}`, action, conclusion)
+	}
+	jobPayload := `{"action":"completed","workflow_job":{"run_id":1,"workflow_name":"ci","conclusion":"failure","html_url":"https://github.com/test-org/test-repo/actions/runs/1/job/2"},"repository":{"full_name":"test-org/test-repo"}}`
+	pushPayload := `{"ref":"refs/heads/main","repository":{"full_name":"test-org/test-repo"}}`
+
+	cases := []struct {
+		name      string
+		method    string
+		event     string
+		payload   string
+		signature string
+		wantCode  int
+		wantSent  string
+	}{
+		{
+			name:      "issue",
+			method:    http.MethodPost,
+			event:     "issues",
+			payload:   issuePayload,
+			signature: signPayload("test-secret", issuePayload),
+			wantCode:  http.StatusNoContent,
+			wantSent:  "Relayed issue",
+		},
+		{
+			name:      "workflow_run_completed",
+			method:    http.MethodPost,
+			event:     "workflow_run",
+			payload:   runPayload("completed", "failure"),
+			signature: signPayload("test-secret", runPayload("completed", "failure")),
+			wantCode:  http.StatusNoContent,
+			wantSent:  "Relayed workflow",
+		},
+		{
+			name:      "workflow_run_requested",
+			method:    http.MethodPost,
+			event:     "workflow_run",
+			payload:   runPayload("requested", ""),
+			signature: signPayload("test-secret", runPayload("requested", "")),
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:      "workflow_job_completed",
+			method:    http.MethodPost,
+			event:     "workflow_job",
+			payload:   jobPayload,
+			signature: signPayload("test-secret", jobPayload),
+			wantCode:  http.StatusNoContent,
+			wantSent:  "https://github.com/test-org/test-repo/actions/runs/1/job/2",
+		},
+		{
+			name:      "workflow_job_in_progress",
+			method:    http.MethodPost,
+			event:     "workflow_job",
+			payload:   `{"action":"in_progress","workflow_job":{"id":1}}`,
+			signature: signPayload("test-secret", `{"action":"in_progress","workflow_job":{"id":1}}`),
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:      "unsupported_event",
+			method:    http.MethodPost,
+			event:     "push",
+			payload:   pushPayload,
+			signature: signPayload("test-secret", pushPayload),
+			wantCode:  http.StatusNoContent,
+		},
+		{
+			name:      "ping",
+			method:    http.MethodPost,
+			event:     "ping",
+			payload:   `{"zen":"Keep it logically awesome."}`,
+			signature: signPayload("test-secret", `{"zen":"Keep it logically awesome."}`),
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "invalid_signature",
+			method:    http.MethodPost,
+			event:     "issues",
+			payload:   issuePayload,
+			signature: signPayload("other-secret", issuePayload),
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "missing_event",
+			method:    http.MethodPost,
+			payload:   issuePayload,
+			signature: signPayload("test-secret", issuePayload),
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:      "invalid_payload",
+			method:    http.MethodPost,
+			event:     "issues",
+			payload:   `{`,
+			signature: signPayload("test-secret", `{`),
+			wantCode:  http.StatusBadRequest,
+		},
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mu.Lock()
+			received = nil
+			mu.Unlock()
+
+			req := httptest.NewRequest(tc.method, "/", strings.NewReader(tc.payload))
+			req.Header.Set("X-GitHub-Event", tc.event)
+			req.Header.Set("X-GitHub-Delivery", "test-delivery")
+			req.Header.Set("X-Hub-Signature-256", tc.signature)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("got status code %d, want %d (body: %s)", got, want, w.Body)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tc.wantSent == "" {
+				if len(received) != 0 {
+					t.Errorf("got %d messages sent, want none", len(received))
+				}
+				return
+			}
+			if len(received) != 1 || !strings.Contains(received[0], tc.wantSent) {
+				t.Errorf("got messages sent %q, want one containing %q", received, tc.wantSent)
+			}
+		})
+	}
+}
+
+func TestServeCommand_HandlerConfig(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		cmd     *ServeCommand
+		wantErr string
+	}{
+		{
+			name:    "missing_secret",
+			cmd:     &ServeCommand{flagWebhookURLs: []string{"https://chat"}},
+			wantErr: "a webhook secret is required",
+		},
+		{
+			name:    "missing_destinations",
+			cmd:     &ServeCommand{flagSecret: "test-secret"},
+			wantErr: "at least one webhook url or a routing config is required",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := tc.cmd.handler(http.DefaultClient, nil, render.Notifiers()); err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("handler() got error %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeCommand_HandlerOutbox(t *testing.T) {
+	t.Parallel()
+
+	ob, err := openOutbox(t.TempDir(), http.DefaultClient, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ob.close() })
+
+	cmd := &ServeCommand{
+		flagSecret:      "test-secret",
+		flagWebhookURLs: []string{"https://chat.example.com"},
+		flagServerURL:   "https://github.com",
+	}
+	cmd.Pipe()
+	h, err := cmd.handler(http.DefaultClient, ob, render.Notifiers())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload := `{"action":"opened","issue":{"title":"Queued issue"},"repository":{"full_name":"test-org/test-repo"}}`
+	deliver := func() int {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+		req.Header.Set("X-GitHub-Event", "issues")
+		req.Header.Set("X-GitHub-Delivery", "test-delivery")
+		req.Header.Set("X-Hub-Signature-256", signPayload("test-secret", payload))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got, want := deliver(), http.StatusAccepted; got != want {
+		t.Errorf("got status code %d for the delivery, want %d", got, want)
+	}
+	if got, want := deliver(), http.StatusOK; got != want {
+		t.Errorf("got status code %d for the redelivery, want %d", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	want := fmt.Sprintf(`{"pending":1,"destinations":[{"destination":%q,"name":"destination 1/1","depth":1}]}`,
+		destinationKey("https://chat.example.com"))
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Errorf("got status %s, want %s", got, want)
+	}
+}
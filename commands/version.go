@@ -0,0 +1,60 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+// VersionCommand prints the version of the binary.
+type VersionCommand struct {
+	cli.BaseCommand
+}
+
+func (c *VersionCommand) Desc() string {
+	return "Print the version"
+}
+
+func (c *VersionCommand) Help() string {
+	return `
+Usage: {{ COMMAND }}
+
+  The version command prints the version, the commit and the platform of the
+  binary.
+`
+}
+
+func (c *VersionCommand) Flags() *cli.FlagSet {
+	return c.NewFlagSet()
+}
+
+func (c *VersionCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	args = f.Args()
+	if len(args) != 0 {
+		return fmt.Errorf("expected 0 arguments, got %q", args)
+	}
+
+	c.Outf("%s", version.HumanVersion())
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+func TestVersionCommand(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name    string
+		args    []string
+		wantOut string
+		wantErr string
+	}{
+		{
+			name:    "version",
+			wantOut: version.HumanVersion(),
+		},
+		{
+			name:    "unexpected_argument",
+			args:    []string{"foo"},
+			wantErr: "expected 0 arguments",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd := &VersionCommand{}
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(context.Background(), tc.args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Run() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() got unexpected error: %v", err)
+			}
+			if got, want := strings.TrimSpace(stdout.String()), tc.wantOut; got != want {
+				t.Errorf("Run() got stdout %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,564 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commands implements the commands of the send-google-chat-webhook
+// CLI.
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+const (
+	jobContextEnvKey    = "JOB_CONTEXT"
+	matrixContextEnvKey = "MATRIX_CONTEXT"
+)
+
+type WorkflowNotificationCommand struct {
+	cli.BaseCommand
+	flagWebhookURLs         []string
+	flagConcurrency         int
+	flagAllowPartialFailure bool
+	flagFailOnError         bool
+	flagIdempotencyKey      string
+	flagPreviousTag         string
+	flagGitHubContextFile   string
+	flagJobStatus           string
+	flagConfig              string
+	flagOnChangeOnly        bool
+	flagSuppressFirstFail   bool
+	flagGitHubAPIURL        string
+	flagGitHubToken         string
+	flagSpace               string
+	flagMessageName         string
+	flagRunning             bool
+	flagAccessToken         string
+	flagCredentialsFile     string
+	flagChatAPIURL          string
+	flagWIFProvider         string
+	flagServiceAccount      string
+	flagSTSURL              string
+	flagIAMCredentialsURL   string
+	jsonFlags               jsonWebhookFlags
+
+	// now returns the current time, it is overridden in tests.
+	now func() time.Time
+}
+
+func (c *WorkflowNotificationCommand) Desc() string {
+	return "Send a message to a Google Chat space"
+}
+
+func (c *WorkflowNotificationCommand) Help() string {
+	return `
+Usage: {{ COMMAND }} [options]
+
+  The chat command sends messages to Google Chat spaces.
+`
+}
+
+func (c *WorkflowNotificationCommand) Flags() *cli.FlagSet {
+	set := c.NewFlagSet()
+
+	f := set.NewSection("COMMAND OPTIONS")
+
+	stringsVar(f, &cli.StringSliceVar{
+		Name:    "webhook-url",
+		Example: "https://chat.googleapis.com/v1/spaces/<SPACE_ID>/messages?key=<KEY>&token=<TOKEN>",
+		Target:  &c.flagWebhookURLs,
+		Usage: `Webhook URL from google chat. Repeat the flag or separate URLs ` +
+			`with newlines to notify several spaces.`,
+	})
+
+	f.IntVar(&cli.IntVar{
+		Name:    "concurrency",
+		Example: "4",
+		Default: 4,
+		Target:  &c.flagConcurrency,
+		Usage:   `Maximum number of destinations notified at the same time.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:    "allow-partial-failure",
+		Default: false,
+		Target:  &c.flagAllowPartialFailure,
+		Usage: `Succeed as long as at least one destination was notified. By ` +
+			`default any failed destination fails the command.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "idempotency-key",
+		Example: "release-v1.2.3",
+		Target:  &c.flagIdempotencyKey,
+		Usage: `Key identifying the notification, so that sending it again is ` +
+			`collapsed by Chat rather than posting a duplicate. By default it ` +
+			`is derived from the repository, run id, attempt, job, matrix, ` +
+			`step and job status.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:    "fail-on-error",
+		Default: true,
+		Target:  &c.flagFailOnError,
+		Usage: `Fail the command when the message could not be sent. When ` +
+			`false, the failure is reported as a warning annotation and the ` +
+			`command succeeds. Invalid configuration always fails the command.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "config",
+		Example: ".github/chat-notify.yml",
+		Target:  &c.flagConfig,
+		Usage: `Routing rules file mapping conditions on the run to the spaces ` +
+			`to notify. The webhook-url destinations are only used when no ` +
+			`route matches.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:    "on-change-only",
+		Default: false,
+		Target:  &c.flagOnChangeOnly,
+		Usage: `Only notify when the job status differs from the previous run ` +
+			`of the same workflow on the same branch, or on any ref for tags, ` +
+			`i.e. when the workflow broke or got fixed.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:    "suppress-first-attempt-failure",
+		Default: false,
+		Target:  &c.flagSuppressFirstFail,
+		Usage: `Don't notify when the first attempt of a run fails. Use it ` +
+			`when failed runs are rerun automatically, so that only failures ` +
+			`of the rerun are reported.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "github-api-url",
+		Example: "https://api.github.com",
+		Default: defaultGitHubAPIURL,
+		EnvVar:  "GITHUB_API_URL",
+		Target:  &c.flagGitHubAPIURL,
+		Usage:   `Base URL of the GitHub REST API, used to look up previous runs and tags.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "github-token",
+		EnvVar: "GITHUB_TOKEN",
+		Target: &c.flagGitHubToken,
+		Usage:  `Token used to call the GitHub REST API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "previous-tag",
+		Example: "v1.2.2",
+		Target:  &c.flagPreviousTag,
+		Usage: `Tag released before the one being created, which cards for ` +
+			`created tags link to the compare view against. By default it is ` +
+			`the tag with the highest version below the created one, looked ` +
+			`up with the GitHub REST API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "github-context-file",
+		Example: "/path/to/github-context.json",
+		Target:  &c.flagGitHubContextFile,
+		Usage: `File holding the github context as JSON. By default it is read ` +
+			`from the GITHUB_CONTEXT environment var or, if not set, ` +
+			`reconstructed from the event payload at GITHUB_EVENT_PATH and ` +
+			`the default GITHUB_* environment vars.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "job-status",
+		EnvVar:  "JOB_STATUS",
+		Example: "failure",
+		Target:  &c.flagJobStatus,
+		Usage: `Status of the job, replacing the one of the job context. Use it ` +
+			`on CI systems not exposing the status to the job, e.g. with ` +
+			`currentBuild.currentResult on Jenkins. Statuses of other CI ` +
+			`systems, e.g. FAILED or ABORTED, are mapped to the ones of GitHub.`,
+	})
+
+	f = set.NewSection("CHAT API OPTIONS")
+
+	f.StringVar(&cli.StringVar{
+		Name:    "space",
+		Example: "spaces/AAAAAAAAAAA",
+		Target:  &c.flagSpace,
+		Usage: `Space to create the message in with the Chat API instead of a ` +
+			`webhook. The name of the created message is written to the ` +
+			`message_name step output.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "message-name",
+		Example: "spaces/AAAAAAAAAAA/messages/BBBBBBBBBBB.BBBBBBBBBBB",
+		Target:  &c.flagMessageName,
+		Usage: `Message previously created with --space to update in place ` +
+			`with the Chat API.`,
+	})
+
+	f.BoolVar(&cli.BoolVar{
+		Name:    "running",
+		Default: false,
+		Target:  &c.flagRunning,
+		Usage: `Show the workflow as running instead of the job status, e.g. ` +
+			`for a message created at the start of the job.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:   "access-token",
+		EnvVar: "GOOGLE_CHAT_ACCESS_TOKEN",
+		Target: &c.flagAccessToken,
+		Usage:  `OAuth 2.0 access token for the Chat API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "credentials-file",
+		Example: "/path/to/service-account-key.json",
+		Target:  &c.flagCredentialsFile,
+		Usage: `Service account key used to authenticate to the Chat API when ` +
+			`no access token is given.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "chat-api-url",
+		Example: chat.DefaultAPIURL,
+		Default: chat.DefaultAPIURL,
+		Target:  &c.flagChatAPIURL,
+		Usage:   `Base URL of the Chat REST API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "workload-identity-provider",
+		Example: "projects/123456789/locations/global/workloadIdentityPools/my-pool/providers/my-provider",
+		Target:  &c.flagWIFProvider,
+		Usage: `Workload identity provider used to authenticate to the Chat API ` +
+			`with the GitHub OIDC token of the job, impersonating ` +
+			`--service-account. The job needs the id-token: write permission.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "service-account",
+		Example: "chat-bot@my-project.iam.gserviceaccount.com",
+		Target:  &c.flagServiceAccount,
+		Usage:   `Service account to impersonate with --workload-identity-provider.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "sts-url",
+		Example: chat.DefaultSTSURL,
+		Default: chat.DefaultSTSURL,
+		Target:  &c.flagSTSURL,
+		Usage:   `Base URL of the Security Token Service API.`,
+	})
+
+	f.StringVar(&cli.StringVar{
+		Name:    "iam-credentials-url",
+		Example: chat.DefaultIAMCredentialsURL,
+		Default: chat.DefaultIAMCredentialsURL,
+		Target:  &c.flagIAMCredentialsURL,
+		Usage:   `Base URL of the IAM Service Account Credentials API.`,
+	})
+
+	c.jsonFlags.register(set)
+
+	return set
+}
+
+func (c *WorkflowNotificationCommand) Run(ctx context.Context, args []string) error {
+	f := c.Flags()
+	if err := f.Parse(args); err != nil {
+		return fmt.Errorf("failed to parse flags: %w", err)
+	}
+
+	args = f.Args()
+	if len(args) != 0 {
+		return fmt.Errorf("expected 0 arguments, got %q", args)
+	}
+
+	ns, err := c.jsonFlags.notifiers()
+	if err != nil {
+		return err
+	}
+
+	ghJSON, jobJSON, err := loadContexts(c.flagGitHubContextFile, c.flagJobStatus, c.GetEnv)
+	if err != nil {
+		return err
+	}
+
+	// The matrix context is "null" for jobs without a matrix.
+	matrixJSON := map[string]any{}
+	if v := c.GetEnv(matrixContextEnvKey); v != "" {
+		if err := json.Unmarshal([]byte(v), &matrixJSON); err != nil {
+			return fmt.Errorf("failed unmarshaling %s: %w", matrixContextEnvKey, err)
+		}
+	}
+
+	client := &http.Client{}
+
+	// The card and quiet hours use the same time, so that they agree around
+	// the boundaries of quiet hours.
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+
+	gh := &githubClient{
+		httpClient: client,
+		baseURL:    c.flagGitHubAPIURL,
+		token:      c.flagGitHubToken,
+	}
+
+	// Runs are skipped before building the message, which may look up the
+	// previous tag with the GitHub REST API.
+	if c.flagSuppressFirstFail && githubevents.RunAttempt(ghJSON) == 1 && githubevents.IsFailedStatus(githubevents.StringValue(jobJSON, "status")) {
+		return c.skip(skippedFirstAttemptFailure, "failure of the first attempt suppressed, waiting for the rerun")
+	}
+
+	// title replaces the one of the message when the workflow broke or got
+	// fixed.
+	var title string
+	if c.flagOnChangeOnly {
+		workflowFile, branch := workflowFileAndBranch(ghJSON)
+		previous, err := gh.previousConclusion(ctx, githubevents.StringValue(ghJSON, githubevents.RepositoryKey),
+			workflowFile, branch, githubevents.StringValue(ghJSON, "run_id"))
+		if err != nil {
+			// Without the previous run the change is unknown, so rather than
+			// missing a broken workflow the notification is sent as usual.
+			c.Errf("failed to look up the previous run, sending the notification: %s", err)
+		} else {
+			switch change := statusChange(previous, githubevents.StringValue(jobJSON, "status")); change {
+			case statusChangeUnchanged:
+				return c.skip(skippedStatusUnchanged, "status unchanged since the previous run (%s), nothing to send", previous)
+			case statusChangeBroken, statusChangeFixed:
+				title = fmt.Sprintf("GitHub workflow %s", change)
+			}
+		}
+	}
+
+	// Quiet hours apply to every way of sending, routes only to webhooks.
+	var cfg *routingConfig
+	in := newRouteInput(ghJSON, jobJSON, matrixJSON)
+	decision := quietHoursDeliver
+	if c.flagConfig != "" {
+		if cfg, err = loadRoutingConfig(c.flagConfig); err != nil {
+			return err
+		}
+		if cfg.QuietHours != nil {
+			decision = cfg.QuietHours.decide(now, githubevents.IsFailedStatus(in.status), in.branch)
+		}
+		if decision == quietHoursSuppress {
+			return c.skip(skippedQuietHours, "quiet hours, nothing to send")
+		}
+	}
+
+	m := workflowMessage(ctx, gh, ghJSON, jobJSON, c.flagPreviousTag, now, c.Errf)
+	if title != "" {
+		m.Title = title
+	}
+
+	status := githubevents.StringValue(jobJSON, "status")
+	if c.flagRunning {
+		status = "running"
+		m.Title = "GitHub workflow running"
+		m.HeaderIconURL = cards.RunningHeaderIconURL
+	}
+	ns = render.WithRunContext(ns, &render.RunContext{Status: status, GitHub: ghJSON, Job: jobJSON})
+
+	key := c.flagIdempotencyKey
+	if key == "" {
+		key = idempotencyKey(ghJSON, jobJSON, matrixJSON, status)
+	}
+
+	if c.flagSpace != "" || c.flagMessageName != "" {
+		if cfg != nil && len(cfg.Routes) > 0 {
+			return fmt.Errorf("routes can't be used with --space or --message-name, only quiet hours of the config apply")
+		}
+		return c.sendWithChatAPI(ctx, client, m, key)
+	}
+
+	var messages []*outgoingMessage
+	if cfg != nil {
+		messages, err = routeMessages(cfg, m, in, c.GetEnv, decision == quietHoursStripMentions, ns)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(messages) == 0 {
+		urls := splitWebhookURLs(c.flagWebhookURLs)
+		if len(urls) == 0 {
+			if c.flagConfig != "" {
+				return c.skip(skippedNoRoute, "no route matched, nothing to send")
+			}
+			return fmt.Errorf("at least one webhook url is required")
+		}
+
+		messages, err = webhookMessages(urls, m, ns)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := setIdempotencyKey(messages, key); err != nil {
+		return err
+	}
+
+	results := sendToAll(ctx, client, messages, c.flagConcurrency)
+
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			c.Errf("%s: failed: %s", r.message.name, r.err)
+			errs = append(errs, fmt.Errorf("%s: %w", r.message.name, r.err))
+			continue
+		}
+		c.Outf("%s: sent", r.message.name)
+	}
+
+	var sendErr error
+	if len(errs) == len(messages) || (len(errs) > 0 && !c.flagAllowPartialFailure) {
+		sendErr = fmt.Errorf("failed to send to %d of %d destinations: %w", len(errs), len(messages), errors.Join(errs...))
+	}
+	return c.finish(m, outputsFromResults(results), sendErr)
+}
+
+// workflowMessage returns the message for the run. Cards for created tags link
+// to the compare view against previousTag, which is looked up with the GitHub
+// REST API when empty. The link is optional, so lookup errors are only
+// reported with errf. Replayed messages are rendered the same way, so that
+// they are the ones sent by the workflow.
+func workflowMessage(ctx context.Context, gh *githubClient, ghJSON, jobJSON map[string]any, previousTag string, now time.Time, errf func(string, ...any)) *cards.Message {
+	m := githubevents.MessageContent(ghJSON, jobJSON, now)
+	if tag := githubevents.CreatedTag(ghJSON); tag != "" && previousTag == "" {
+		var err error
+		if previousTag, err = gh.previousTag(ctx, githubevents.StringValue(ghJSON, githubevents.RepositoryKey), tag); err != nil {
+			errf("failed to look up the previous tag: %s", err)
+		}
+	}
+	githubevents.AddTagCompareLink(m, ghJSON, previousTag)
+	return m
+}
+
+// sendWithChatAPI creates or updates the message with the Chat API.
+func (c *WorkflowNotificationCommand) sendWithChatAPI(ctx context.Context, client *http.Client, m *cards.Message, idempotencyKey string) error {
+	var ts chat.TokenSource
+	switch {
+	case c.flagAccessToken != "":
+		ts = chat.StaticTokenSource(c.flagAccessToken)
+	case c.flagCredentialsFile != "":
+		sa, err := chat.NewServiceAccountTokenSource(client, c.flagCredentialsFile)
+		if err != nil {
+			return err
+		}
+		ts = sa
+	case c.flagWIFProvider != "":
+		if c.flagServiceAccount == "" {
+			return fmt.Errorf("a service account is required with a workload identity provider")
+		}
+		ts = &chat.WorkloadIdentityTokenSource{
+			HTTPClient:        client,
+			OIDCRequestURL:    c.GetEnv(chat.ActionsIDTokenRequestURLEnvKey),
+			OIDCRequestToken:  c.GetEnv(chat.ActionsIDTokenRequestTokenEnvKey),
+			Provider:          c.flagWIFProvider,
+			ServiceAccount:    c.flagServiceAccount,
+			STSURL:            c.flagSTSURL,
+			IAMCredentialsURL: c.flagIAMCredentialsURL,
+		}
+	default:
+		return fmt.Errorf("an access token, a credentials file or a workload identity provider is required to use the Chat API")
+	}
+
+	api := &chat.Client{
+		HTTPClient:  client,
+		BaseURL:     c.flagChatAPIURL,
+		TokenSource: ts,
+	}
+
+	b, err := cards.RequestBody(m)
+	if err != nil {
+		return fmt.Errorf("failed to generate message body: %w", err)
+	}
+
+	var msg *chat.Message
+	if c.flagMessageName != "" {
+		msg, err = api.PatchMessage(ctx, c.flagMessageName, b)
+		if err != nil {
+			return c.finish(m, chatAPIErrorOutputs(err), fmt.Errorf("failed to update message: %w", err))
+		}
+		c.Outf("updated message %s", msg.Name)
+	} else {
+		msg, err = api.CreateMessage(ctx, c.flagSpace, b, idempotencyKey)
+		if err != nil {
+			return c.finish(m, chatAPIErrorOutputs(err), fmt.Errorf("failed to create message: %w", err))
+		}
+		c.Outf("created message %s", msg.Name)
+	}
+
+	return c.finish(m, &runOutputs{
+		statusCode:   http.StatusOK,
+		messageName:  msg.Name,
+		threadName:   msg.Thread.Name,
+		destinations: 1,
+	}, nil)
+}
+
+// finish sets the step outputs and the job summary after sending. A delivery
+// error fails the command unless --fail-on-error=false, in which case it is
+// reported as a warning annotation instead.
+func (c *WorkflowNotificationCommand) finish(m *cards.Message, o *runOutputs, sendErr error) error {
+	if sendErr != nil {
+		o.err = sendErr.Error()
+	}
+	if err := c.setOutputs(o); err != nil {
+		return err
+	}
+	if o.destinations > 0 {
+		if err := appendStepSummary(c.GetEnv(githubStepSummaryEnvKey), m); err != nil {
+			return fmt.Errorf("failed to write job summary: %w", err)
+		}
+	}
+
+	if sendErr == nil || c.flagFailOnError {
+		return sendErr
+	}
+	c.Outf("::warning title=Google Chat notification failed::%s", escapeWorkflowCommandData(sendErr.Error()))
+	return nil
+}
+
+// skip reports that nothing is sent, and why.
+func (c *WorkflowNotificationCommand) skip(reason, format string, args ...any) error {
+	c.Outf(format, args...)
+	return c.setOutputs(&runOutputs{skippedReason: reason})
+}
+
+// setOutputs writes the step outputs to $GITHUB_OUTPUT.
+func (c *WorkflowNotificationCommand) setOutputs(o *runOutputs) error {
+	if err := setGitHubOutputs(c.GetEnv(githubOutputEnvKey), o.values()); err != nil {
+		return fmt.Errorf("failed to set step outputs: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abcxyz/pkg/cli"
+	"github.com/google-github-actions/send-google-chat-webhook/internal/chattest"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWorkflowNotificationCommand(t *testing.T) {
+	t.Parallel()
+
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ok.Close)
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(broken.Close)
+
+	env := map[string]string{
+		"GITHUB_CONTEXT": `{"repository":"test-repository","workflow":"test-workflow"}`,
+		"JOB_CONTEXT":    `{"status":"success"}`,
+	}
+
+	github := newFakeGitHub(t, `[{"id":1,"conclusion":"success"}]`)
+	onChangeEnv := func(status string) map[string]string {
+		return map[string]string{
+			"GITHUB_CONTEXT": `{"repository":"test-org/test-repo","run_id":"2","ref":"refs/heads/main",` +
+				`"workflow_ref":"test-org/test-repo/.github/workflows/ci.yml@refs/heads/main"}`,
+			"JOB_CONTEXT":    fmt.Sprintf(`{"status":%q}`, status),
+			"GITHUB_API_URL": github.URL,
+			"GITHUB_TOKEN":   "test-token",
+		}
+	}
+
+	chatAPI := chattest.NewAPIServer(t, nil)
+	chatAPIEnv := map[string]string{
+		"GITHUB_CONTEXT":           env["GITHUB_CONTEXT"],
+		"JOB_CONTEXT":              env["JOB_CONTEXT"],
+		"GOOGLE_CHAT_ACCESS_TOKEN": "test-token",
+	}
+
+	googleAuth := chattest.NewGoogleAuthServer(t)
+	wifEnv := map[string]string{
+		"GITHUB_CONTEXT":                 env["GITHUB_CONTEXT"],
+		"JOB_CONTEXT":                    env["JOB_CONTEXT"],
+		"ACTIONS_ID_TOKEN_REQUEST_URL":   googleAuth.URL + "/oidc?api-version=2.0",
+		"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "test-request-token",
+	}
+
+	eventFile := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(eventFile, []byte(`{"ref":"refs/heads/main"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	contextFile := filepath.Join(t.TempDir(), "github-context.json")
+	if err := os.WriteFile(contextFile, []byte(env["GITHUB_CONTEXT"]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	quietConfig := filepath.Join(t.TempDir(), "chat-notify.yml")
+	if err := os.WriteFile(quietConfig, []byte(`
+quiet_hours:
+  time_zone: 'UTC'
+  windows:
+    - start: '20:00'
+      end: '08:00'
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	routesConfig := filepath.Join(t.TempDir(), "chat-notify.yml")
+	if err := os.WriteFile(routesConfig, []byte(`
+routes:
+  - name: 'failures'
+    when:
+      statuses: ['failure']
+    destinations: ['WEBHOOK_URL_FAILURES']
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		env         map[string]string
+		args        []string
+		now         time.Time
+		wantOut     string
+		wantOutputs map[string]string
+		wantErr     string
+	}{
+		{
+			name:    "missing_github_context",
+			env:     map[string]string{},
+			args:    []string{"--webhook-url", ok.URL},
+			wantErr: "environment vars GITHUB_CONTEXT and GITHUB_EVENT_PATH not set",
+		},
+		{
+			name: "github_event_path",
+			env: map[string]string{
+				"GITHUB_EVENT_PATH": eventFile,
+				"GITHUB_REPOSITORY": "test-repository",
+				"GITHUB_EVENT_NAME": "push",
+				"JOB_CONTEXT":       `{"status":"success"}`,
+			},
+			args:    []string{"--webhook-url", ok.URL},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name:    "github_context_file",
+			env:     map[string]string{"JOB_CONTEXT": `{"status":"success"}`},
+			args:    []string{"--webhook-url", ok.URL, "--github-context-file", contextFile},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name: "jenkins",
+			env: map[string]string{
+				"JENKINS_URL":  "https://jenkins.example.com/",
+				"JOB_NAME":     "org/repo/main",
+				"BUILD_NUMBER": "7",
+				"BUILD_URL":    "https://jenkins.example.com/job/org/job/repo/job/main/7/",
+			},
+			args:    []string{"--webhook-url", ok.URL, "--job-status", "FAILURE"},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name:    "missing_webhook_url",
+			env:     env,
+			wantErr: "at least one webhook url is required",
+		},
+		{
+			name:    "single_destination",
+			env:     env,
+			args:    []string{"--webhook-url", ok.URL},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name:    "newline_separated_destinations",
+			env:     env,
+			args:    []string{"--webhook-url", ok.URL + "\n" + ok.URL},
+			wantOut: "destination 2/2: sent",
+			wantOutputs: map[string]string{
+				"status_code":    "200",
+				"message_name":   "",
+				"thread_name":    "",
+				"destinations":   "2",
+				"skipped_reason": "",
+				"error":          "",
+			},
+		},
+		{
+			name:    "failed_destination",
+			env:     env,
+			args:    []string{"--webhook-url", ok.URL, "--webhook-url", broken.URL},
+			wantErr: "failed to send to 1 of 2 destinations",
+		},
+		{
+			name:    "allow_partial_failure",
+			env:     env,
+			args:    []string{"--webhook-url", ok.URL, "--webhook-url", broken.URL, "--allow-partial-failure"},
+			wantOut: "destination 1/2: sent",
+		},
+		{
+			name:    "on_change_only_unchanged",
+			env:     onChangeEnv("success"),
+			args:    []string{"--webhook-url", ok.URL, "--on-change-only"},
+			wantOut: "status unchanged since the previous run (success), nothing to send",
+			wantOutputs: map[string]string{
+				"status_code":    "",
+				"message_name":   "",
+				"thread_name":    "",
+				"destinations":   "0",
+				"skipped_reason": "status_unchanged",
+				"error":          "",
+			},
+		},
+		{
+			name:    "on_change_only_broken",
+			env:     onChangeEnv("failure"),
+			args:    []string{"--webhook-url", ok.URL, "--on-change-only"},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name: "on_change_only_lookup_failed",
+			env: map[string]string{
+				"GITHUB_CONTEXT": onChangeEnv("success")["GITHUB_CONTEXT"],
+				"JOB_CONTEXT":    `{"status":"success"}`,
+				"GITHUB_API_URL": github.URL,
+				"GITHUB_TOKEN":   "invalid-token",
+			},
+			args:    []string{"--webhook-url", ok.URL, "--on-change-only"},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name: "suppress_first_attempt_failure",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"test-repository","run_attempt":"1"}`,
+				"JOB_CONTEXT":    `{"status":"failure"}`,
+			},
+			args:    []string{"--webhook-url", broken.URL, "--suppress-first-attempt-failure"},
+			wantOut: "failure of the first attempt suppressed",
+		},
+		{
+			name: "suppress_first_attempt_failure_rerun",
+			env: map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"test-repository","run_attempt":"2"}`,
+				"JOB_CONTEXT":    `{"status":"failure"}`,
+			},
+			args:    []string{"--webhook-url", ok.URL, "--suppress-first-attempt-failure"},
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name:    "quiet_hours",
+			env:     env,
+			args:    []string{"--webhook-url", broken.URL, "--config", quietConfig},
+			now:     time.Date(2023, time.April, 25, 22, 0, 0, 0, time.UTC),
+			wantOut: "quiet hours, nothing to send",
+		},
+		{
+			name:    "outside_quiet_hours",
+			env:     env,
+			args:    []string{"--webhook-url", ok.URL, "--config", quietConfig},
+			now:     time.Date(2023, time.April, 25, 12, 0, 0, 0, time.UTC),
+			wantOut: "destination 1/1: sent",
+		},
+		{
+			name:    "chat_api_create",
+			env:     chatAPIEnv,
+			args:    []string{"--space", "spaces/AAAA", "--running", "--chat-api-url", chatAPI.URL},
+			wantOut: "created message spaces/AAAA/messages/BBBB",
+			wantOutputs: map[string]string{
+				"status_code":    "200",
+				"message_name":   "spaces/AAAA/messages/BBBB",
+				"thread_name":    "",
+				"destinations":   "1",
+				"skipped_reason": "",
+				"error":          "",
+			},
+		},
+		{
+			name:    "chat_api_quiet_hours",
+			env:     chatAPIEnv,
+			args:    []string{"--space", "spaces/AAAA", "--chat-api-url", broken.URL, "--config", quietConfig},
+			now:     time.Date(2023, time.April, 25, 22, 0, 0, 0, time.UTC),
+			wantOut: "quiet hours, nothing to send",
+		},
+		{
+			name:    "chat_api_outside_quiet_hours",
+			env:     chatAPIEnv,
+			args:    []string{"--space", "spaces/AAAA", "--chat-api-url", chatAPI.URL, "--config", quietConfig},
+			now:     time.Date(2023, time.April, 25, 12, 0, 0, 0, time.UTC),
+			wantOut: "created message spaces/AAAA/messages/BBBB",
+		},
+		{
+			name:    "chat_api_routes",
+			env:     chatAPIEnv,
+			args:    []string{"--space", "spaces/AAAA", "--chat-api-url", chatAPI.URL, "--config", routesConfig},
+			wantErr: "routes can't be used with --space or --message-name",
+		},
+		{
+			name:    "chat_api_patch",
+			env:     chatAPIEnv,
+			args:    []string{"--message-name", "spaces/AAAA/messages/BBBB", "--chat-api-url", chatAPI.URL},
+			wantOut: "updated message spaces/AAAA/messages/BBBB",
+		},
+		{
+			name: "chat_api_workload_identity",
+			env:  wifEnv,
+			args: []string{
+				"--space", "spaces/AAAA", "--chat-api-url", chatAPI.URL,
+				"--workload-identity-provider", chattest.WIFProvider,
+				"--service-account", "chat-bot@test-project.iam.gserviceaccount.com",
+				"--sts-url", googleAuth.URL, "--iam-credentials-url", googleAuth.URL,
+			},
+			wantOut: "created message spaces/AAAA/messages/BBBB",
+		},
+		{
+			name:    "chat_api_workload_identity_missing_service_account",
+			env:     wifEnv,
+			args:    []string{"--space", "spaces/AAAA", "--workload-identity-provider", chattest.WIFProvider},
+			wantErr: "a service account is required",
+		},
+		{
+			name:    "chat_api_missing_credentials",
+			env:     env,
+			args:    []string{"--space", "spaces/AAAA", "--chat-api-url", chatAPI.URL},
+			wantErr: "an access token, a credentials file or a workload identity provider is required",
+		},
+		{
+			name:    "fail_open",
+			env:     env,
+			args:    []string{"--webhook-url", broken.URL, "--fail-on-error=false"},
+			wantOut: "::warning title=Google Chat notification failed::failed to send to 1 of 1 destinations",
+			wantOutputs: map[string]string{
+				"status_code":    "404",
+				"message_name":   "",
+				"thread_name":    "",
+				"destinations":   "0",
+				"skipped_reason": "",
+				"error": "failed to send to 1 of 1 destinations: destination 1/1: " +
+					"unexpected HTTP status code 404 (Not Found)\n got body: \n hint: the space or the message does not exist, it may have been deleted",
+			},
+		},
+		{
+			name:    "fail_open_invalid_config",
+			env:     env,
+			args:    []string{"--webhook-url", broken.URL, "--fail-on-error=false", "--config", "does-not-exist.yml"},
+			wantErr: "does-not-exist.yml",
+		},
+		{
+			name:    "fail_open_chat_api",
+			env:     chatAPIEnv,
+			args:    []string{"--space", "spaces/AAAA", "--chat-api-url", broken.URL, "--fail-on-error=false"},
+			wantOut: "::warning title=Google Chat notification failed::failed to create message",
+		},
+		{
+			name:    "allow_partial_failure_all_failed",
+			env:     env,
+			args:    []string{"--webhook-url", broken.URL, "--allow-partial-failure"},
+			wantErr: "failed to send to 1 of 1 destinations",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			outputFile := filepath.Join(t.TempDir(), "github_output")
+			env := maps.Clone(tc.env)
+			env["GITHUB_OUTPUT"] = outputFile
+
+			var cmd WorkflowNotificationCommand
+			cmd.SetLookupEnv(cli.MapLookuper(env))
+			if !tc.now.IsZero() {
+				cmd.now = func() time.Time { return tc.now }
+			}
+			_, stdout, _ := cmd.Pipe()
+
+			err := cmd.Run(context.Background(), tc.args)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Run() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() got unexpected error: %v", err)
+			}
+			if got := stdout.String(); !strings.Contains(got, tc.wantOut) {
+				t.Errorf("Run() got stdout %q, want it to contain %q", got, tc.wantOut)
+			}
+			if tc.wantOutputs != nil {
+				if diff := cmp.Diff(tc.wantOutputs, readGitHubOutputs(t, outputFile)); diff != "" {
+					t.Errorf("Run() got unexpected outputs diff (-want, +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkflowNotificationCommand_PreviousTag(t *testing.T) {
+	t.Parallel()
+
+	github := newFakeGitHub(t, `[]`)
+
+	cases := []struct {
+		name     string
+		args     []string
+		wantLink string
+	}{
+		{
+			name:     "looked_up",
+			wantLink: "https://github.com/test-org/test-repo/compare/v1.2.0...v1.3.0",
+		},
+		{
+			name:     "flag",
+			args:     []string{"--previous-tag", "v1.1.0"},
+			wantLink: "https://github.com/test-org/test-repo/compare/v1.1.0...v1.3.0",
+		},
+		{
+			name: "lookup_failed",
+			args: []string{"--github-token", "invalid-token"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			bodies := make(chan string, 1)
+			chat := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				bodies <- string(b)
+			}))
+			t.Cleanup(chat.Close)
+
+			var cmd WorkflowNotificationCommand
+			cmd.SetLookupEnv(cli.MapLookuper(map[string]string{
+				"GITHUB_CONTEXT": `{"repository":"test-org/test-repo","server_url":"https://github.com","event_name":"create",` +
+					`"event":{"ref":"v1.3.0","ref_type":"tag"}}`,
+				"JOB_CONTEXT":    `{"status":"success"}`,
+				"GITHUB_API_URL": github.URL,
+				"GITHUB_TOKEN":   "test-token",
+			}))
+			cmd.Pipe()
+
+			if err := cmd.Run(context.Background(), append([]string{"--webhook-url", chat.URL}, tc.args...)); err != nil {
+				t.Fatal(err)
+			}
+			got := <-bodies
+			if tc.wantLink == "" && strings.Contains(got, "/compare/") {
+				t.Errorf("Run() sent %s, want no compare link", got)
+			}
+			if !strings.Contains(got, tc.wantLink) {
+				t.Errorf("Run() sent %s, want it to link to %s", got, tc.wantLink)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Environment vars holding the github context and the path of the event
+// payload.
+const (
+	ContextEnvKey   = "GITHUB_CONTEXT"
+	EventPathEnvKey = "GITHUB_EVENT_PATH"
+)
+
+// contextEnvVars maps the default environment variables of GitHub
+// Actions to the keys of the github context they hold.
+// https://docs.github.com/en/actions/learn-github-actions/variables#default-environment-variables
+var contextEnvVars = map[string]string{
+	"GITHUB_ACTION":           "action",
+	"GITHUB_ACTOR":            "actor",
+	"GITHUB_ACTOR_ID":         "actor_id",
+	"GITHUB_API_URL":          "api_url",
+	"GITHUB_BASE_REF":         "base_ref",
+	"GITHUB_EVENT_NAME":       "event_name",
+	"GITHUB_EVENT_PATH":       "event_path",
+	"GITHUB_GRAPHQL_URL":      "graphql_url",
+	"GITHUB_HEAD_REF":         "head_ref",
+	"GITHUB_JOB":              "job",
+	"GITHUB_REF":              "ref",
+	"GITHUB_REF_NAME":         "ref_name",
+	"GITHUB_REF_PROTECTED":    "ref_protected",
+	"GITHUB_REF_TYPE":         "ref_type",
+	"GITHUB_REPOSITORY":       "repository",
+	"GITHUB_REPOSITORY_ID":    "repository_id",
+	"GITHUB_REPOSITORY_OWNER": "repository_owner",
+	"GITHUB_RETENTION_DAYS":   "retention_days",
+	"GITHUB_RUN_ATTEMPT":      "run_attempt",
+	"GITHUB_RUN_ID":           "run_id",
+	"GITHUB_RUN_NUMBER":       "run_number",
+	"GITHUB_SERVER_URL":       "server_url",
+	"GITHUB_SHA":              "sha",
+	"GITHUB_TRIGGERING_ACTOR": "triggering_actor",
+	"GITHUB_WORKFLOW":         "workflow",
+	"GITHUB_WORKFLOW_REF":     "workflow_ref",
+	"GITHUB_WORKFLOW_SHA":     "workflow_sha",
+	"GITHUB_WORKSPACE":        "workspace",
+}
+
+// LoadContext returns the github context, read from contextFile when
+// set, or else from the GITHUB_CONTEXT environment var. Large events can
+// exceed the size limit of environment vars, so without either the context is
+// reconstructed from the event payload at GITHUB_EVENT_PATH and the default
+// GITHUB_* environment vars.
+func LoadContext(contextFile string, getenv func(string) string) (map[string]any, error) {
+	if contextFile != "" {
+		b, err := os.ReadFile(contextFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read github context file: %w", err)
+		}
+		ghJSON := map[string]any{}
+		if err := json.Unmarshal(b, &ghJSON); err != nil {
+			return nil, fmt.Errorf("failed unmarshaling github context file: %w", err)
+		}
+		return ghJSON, nil
+	}
+
+	if v := getenv(ContextEnvKey); v != "" {
+		ghJSON := map[string]any{}
+		if err := json.Unmarshal([]byte(v), &ghJSON); err != nil {
+			return nil, fmt.Errorf("failed unmarshaling %s: %w", ContextEnvKey, err)
+		}
+		return ghJSON, nil
+	}
+
+	eventPath := getenv(EventPathEnvKey)
+	if eventPath == "" {
+		return nil, fmt.Errorf("environment vars %s and %s not set", ContextEnvKey, EventPathEnvKey)
+	}
+	b, err := os.ReadFile(eventPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read event payload: %w", err)
+	}
+	return contextFromEvent(b, getenv)
+}
+
+// contextFromEvent reconstructs the github context from the event
+// payload and the default GITHUB_* environment vars.
+func contextFromEvent(payload []byte, getenv func(string) string) (map[string]any, error) {
+	event := map[string]any{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling event payload: %w", err)
+	}
+
+	ghJSON := map[string]any{
+		EventKey: event,
+	}
+	for envVar, key := range contextEnvVars {
+		if v := getenv(envVar); v != "" {
+			ghJSON[key] = v
+		}
+	}
+	return ghJSON, nil
+}
+
+// ContextFromPayload reconstructs the github context for an event
+// payload received outside of GitHub Actions, e.g. by a webhook. The values of
+// the GITHUB_* environment vars are taken from the payload where it has them.
+func ContextFromPayload(eventName, serverURL string, payload []byte) (map[string]any, error) {
+	event := map[string]any{}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("failed unmarshaling event payload: %w", err)
+	}
+
+	env := map[string]string{
+		"GITHUB_EVENT_NAME":       eventName,
+		"GITHUB_SERVER_URL":       serverURL,
+		"GITHUB_REPOSITORY":       StringValue(MapValue(event, "repository"), "full_name"),
+		"GITHUB_TRIGGERING_ACTOR": StringValue(MapValue(event, "sender"), "login"),
+	}
+	if ref := StringValue(event, RefKey); strings.HasPrefix(ref, "refs/") {
+		env["GITHUB_REF"] = ref
+	}
+	if run, ok := event["workflow_run"].(map[string]any); ok {
+		env["GITHUB_WORKFLOW"] = StringValue(run, "name")
+		env["GITHUB_RUN_ID"] = jsonNumberString(run["id"])
+		env["GITHUB_RUN_ATTEMPT"] = jsonNumberString(run["run_attempt"])
+		env["GITHUB_REF"] = "refs/heads/" + StringValue(run, "head_branch")
+	}
+
+	// Jobs and checks link to their own page rather than to the run.
+	var runURL string
+	if job, ok := event["workflow_job"].(map[string]any); ok {
+		env["GITHUB_WORKFLOW"] = StringValue(job, "workflow_name")
+		env["GITHUB_JOB"] = StringValue(job, "name")
+		env["GITHUB_RUN_ID"] = jsonNumberString(job["run_id"])
+		env["GITHUB_RUN_ATTEMPT"] = jsonNumberString(job["run_attempt"])
+		env["GITHUB_SHA"] = StringValue(job, "head_sha")
+		if b := StringValue(job, "head_branch"); b != "" {
+			env["GITHUB_REF"] = "refs/heads/" + b
+		}
+		runURL = StringValue(job, "html_url")
+	}
+	var suite map[string]any
+	if check, ok := event["check_run"].(map[string]any); ok {
+		env["GITHUB_WORKFLOW"] = StringValue(check, "name")
+		runURL = StringValue(check, "html_url")
+		suite = MapValue(check, "check_suite")
+	} else if v, ok := event["check_suite"].(map[string]any); ok {
+		env["GITHUB_WORKFLOW"] = StringValue(MapValue(v, "app"), "name")
+		suite = v
+	}
+	if suite != nil {
+		env["GITHUB_SHA"] = StringValue(suite, "head_sha")
+		if b := StringValue(suite, "head_branch"); b != "" {
+			env["GITHUB_REF"] = "refs/heads/" + b
+		}
+		// Check suites have no page of their own, so they link to the checks of
+		// their commit.
+		if runURL == "" && env["GITHUB_SHA"] != "" {
+			runURL = fmt.Sprintf("%s/%s/commit/%s/checks", cmp.Or(serverURL, defaultServerURL), env["GITHUB_REPOSITORY"], env["GITHUB_SHA"])
+		}
+	}
+
+	ghJSON, err := contextFromEvent(payload, func(k string) string { return env[k] })
+	if err != nil {
+		return nil, err
+	}
+	if runURL != "" {
+		ghJSON[RunURLKey] = runURL
+	}
+	return ghJSON, nil
+}
+
+// JobContextFromPayload returns a job context for an event payload received
+// outside of GitHub Actions, with the conclusion of the workflow run or job as
+// the status.
+func JobContextFromPayload(ghJSON map[string]any) map[string]any {
+	event := MapValue(ghJSON, EventKey)
+	for _, key := range []string{"workflow_run", "workflow_job", "check_suite", "check_run"} {
+		if v := StringValue(MapValue(event, key), "conclusion"); v != "" {
+			return map[string]any{"status": v}
+		}
+	}
+	return map[string]any{}
+}
+
+// jsonNumberString formats a decoded JSON number, e.g. an id, as in the
+// GITHUB_* environment vars. It returns an empty string for other values.
+func jsonNumberString(v any) string {
+	f, ok := v.(float64)
+	if !ok {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -0,0 +1,270 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLoadContext(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	eventFile := filepath.Join(dir, "event.json")
+	if err := os.WriteFile(eventFile, []byte(`{"action":"opened","issue":{"number":1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	contextFile := filepath.Join(dir, "github-context.json")
+	if err := os.WriteFile(contextFile, []byte(`{"repository":"from-file"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	invalidFile := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalidFile, []byte(`{`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name        string
+		contextFile string
+		env         map[string]string
+		want        map[string]any
+		wantErr     string
+	}{
+		{
+			name:        "context_file",
+			contextFile: contextFile,
+			env:         map[string]string{"GITHUB_CONTEXT": `{"repository":"from-env"}`},
+			want:        map[string]any{"repository": "from-file"},
+		},
+		{
+			name: "context_env",
+			env: map[string]string{
+				"GITHUB_CONTEXT":    `{"repository":"from-env"}`,
+				"GITHUB_EVENT_PATH": eventFile,
+			},
+			want: map[string]any{"repository": "from-env"},
+		},
+		{
+			name: "event_path",
+			env: map[string]string{
+				"GITHUB_EVENT_PATH":       eventFile,
+				"GITHUB_EVENT_NAME":       "issues",
+				"GITHUB_REPOSITORY":       "test-org/test-repo",
+				"GITHUB_REF":              "refs/heads/main",
+				"GITHUB_RUN_ID":           "1",
+				"GITHUB_RUN_ATTEMPT":      "2",
+				"GITHUB_SERVER_URL":       "https://github.com",
+				"GITHUB_TRIGGERING_ACTOR": "octocat",
+				"GITHUB_WORKFLOW":         "ci",
+				"GITHUB_WORKFLOW_REF":     "test-org/test-repo/.github/workflows/ci.yml@refs/heads/main",
+				"UNRELATED":               "ignored",
+			},
+			want: map[string]any{
+				"event": map[string]any{
+					"action": "opened",
+					"issue":  map[string]any{"number": float64(1)},
+				},
+				"event_path":       eventFile,
+				"event_name":       "issues",
+				"repository":       "test-org/test-repo",
+				"ref":              "refs/heads/main",
+				"run_id":           "1",
+				"run_attempt":      "2",
+				"server_url":       "https://github.com",
+				"triggering_actor": "octocat",
+				"workflow":         "ci",
+				"workflow_ref":     "test-org/test-repo/.github/workflows/ci.yml@refs/heads/main",
+			},
+		},
+		{
+			name:    "nothing_set",
+			env:     map[string]string{},
+			wantErr: "environment vars GITHUB_CONTEXT and GITHUB_EVENT_PATH not set",
+		},
+		{
+			name:        "missing_context_file",
+			contextFile: filepath.Join(dir, "does-not-exist.json"),
+			wantErr:     "failed to read github context file",
+		},
+		{
+			name:    "invalid_context_env",
+			env:     map[string]string{"GITHUB_CONTEXT": `{`},
+			wantErr: "failed unmarshaling GITHUB_CONTEXT",
+		},
+		{
+			name:    "invalid_event_payload",
+			env:     map[string]string{"GITHUB_EVENT_PATH": invalidFile},
+			wantErr: "failed unmarshaling event payload",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := LoadContext(tc.contextFile, func(k string) string { return tc.env[k] })
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("LoadContext() got error %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadContext() got unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("LoadContext() got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContextFromPayload(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		eventName string
+		payload   string
+		want      map[string]any
+		wantJob   map[string]any
+	}{
+		{
+			name:      "workflow_run",
+			eventName: "workflow_run",
+			payload: `{
+				"action": "completed",
+				"workflow_run": {"name": "ci", "id": 1234567890, "run_attempt": 2, "head_branch": "main", "conclusion": "failure"},
+				"repository": {"full_name": "test-org/test-repo"},
+				"sender": {"login": "octocat"}
+			}`,
+			want: map[string]any{
+				"event_name":       "workflow_run",
+				"server_url":       "https://github.com",
+				"repository":       "test-org/test-repo",
+				"triggering_actor": "octocat",
+				"workflow":         "ci",
+				"run_id":           "1234567890",
+				"run_attempt":      "2",
+				"ref":              "refs/heads/main",
+			},
+			wantJob: map[string]any{"status": "failure"},
+		},
+		{
+			name:      "workflow_job",
+			eventName: "workflow_job",
+			payload: `{
+				"action": "completed",
+				"workflow_job": {
+					"name": "test", "workflow_name": "ci", "run_id": 1234567890, "run_attempt": 1,
+					"head_branch": "main", "head_sha": "abc123", "conclusion": "cancelled",
+					"html_url": "https://github.com/test-org/test-repo/actions/runs/1234567890/job/42"
+				},
+				"repository": {"full_name": "test-org/test-repo"},
+				"sender": {"login": "octocat"}
+			}`,
+			want: map[string]any{
+				"event_name":       "workflow_job",
+				"server_url":       "https://github.com",
+				"repository":       "test-org/test-repo",
+				"triggering_actor": "octocat",
+				"workflow":         "ci",
+				"job":              "test",
+				"run_id":           "1234567890",
+				"run_attempt":      "1",
+				"sha":              "abc123",
+				"ref":              "refs/heads/main",
+				"run_url":          "https://github.com/test-org/test-repo/actions/runs/1234567890/job/42",
+			},
+			wantJob: map[string]any{"status": "cancelled"},
+		},
+		{
+			name:      "check_run",
+			eventName: "check_run",
+			payload: `{
+				"action": "completed",
+				"check_run": {
+					"name": "lint", "conclusion": "timed_out",
+					"html_url": "https://github.com/test-org/test-repo/runs/42",
+					"check_suite": {"head_branch": "main", "head_sha": "abc123"}
+				},
+				"repository": {"full_name": "test-org/test-repo"},
+				"sender": {"login": "octocat"}
+			}`,
+			want: map[string]any{
+				"event_name":       "check_run",
+				"server_url":       "https://github.com",
+				"repository":       "test-org/test-repo",
+				"triggering_actor": "octocat",
+				"workflow":         "lint",
+				"sha":              "abc123",
+				"ref":              "refs/heads/main",
+				"run_url":          "https://github.com/test-org/test-repo/runs/42",
+			},
+			wantJob: map[string]any{"status": "timed_out"},
+		},
+		{
+			name:      "check_suite",
+			eventName: "check_suite",
+			payload: `{
+				"action": "completed",
+				"check_suite": {"app": {"name": "Test CI"}, "head_branch": "main", "head_sha": "abc123", "conclusion": "success"},
+				"repository": {"full_name": "test-org/test-repo"},
+				"sender": {"login": "octocat"}
+			}`,
+			want: map[string]any{
+				"event_name":       "check_suite",
+				"server_url":       "https://github.com",
+				"repository":       "test-org/test-repo",
+				"triggering_actor": "octocat",
+				"workflow":         "Test CI",
+				"sha":              "abc123",
+				"ref":              "refs/heads/main",
+				"run_url":          "https://github.com/test-org/test-repo/commit/abc123/checks",
+			},
+			wantJob: map[string]any{"status": "success"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ghJSON, err := ContextFromPayload(tc.eventName, "https://github.com", []byte(tc.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := map[string]any{}
+			for k, v := range ghJSON {
+				if k != EventKey {
+					got[k] = v
+				}
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ContextFromPayload() got unexpected diff (-want, +got):\n%s", diff)
+			}
+
+			if diff := cmp.Diff(tc.wantJob, JobContextFromPayload(ghJSON)); diff != "" {
+				t.Errorf("JobContextFromPayload() got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
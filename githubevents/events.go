@@ -0,0 +1,407 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+)
+
+// maxExcerptLength is the maximum number of characters of a user provided body
+// (discussion, comment, etc.) that is shown on the card.
+const maxExcerptLength = 300
+
+var (
+	markdownCommentRe = regexp.MustCompile(`(?s)<!--.*?-->`)
+	markdownHeadingRe = regexp.MustCompile(`^#{1,6}\s+(.*)$`)
+	markdownListRe    = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	markdownLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	markdownBoldRe    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownBoldAltRe = regexp.MustCompile(`__(.+?)__`)
+	markdownItalicRe  = regexp.MustCompile(`\*([^*\s][^*]*?)\*`)
+	markdownStrikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+	markdownCodeRe    = regexp.MustCompile("`([^`]+)`")
+)
+
+// issueMessageBodyContent returns cards.Message for the issues event. The
+// timestamp follows the action, e.g. closed_at for a closed issue, and for
+// actions changing a label or an assignee the changed one is shown.
+func issueMessageBodyContent(ghJSON, event map[string]any) *cards.Message {
+	issue := MapValue(event, "issue")
+	action := StringValue(event, eventActionKey)
+
+	var timestamp string
+	switch action {
+	case "opened":
+		timestamp = StringValue(issue, createdAtKey)
+	case "closed":
+		timestamp = StringValue(issue, "closed_at")
+	default:
+		timestamp = StringValue(issue, "updated_at")
+	}
+	if timestamp == "" {
+		timestamp = StringValue(issue, createdAtKey)
+	}
+
+	var details []cards.Detail
+	switch action {
+	case "labeled", "unlabeled":
+		details = append(details, cards.Detail{
+			Label: capitalize(action) + " label",
+			Value: StringValue(MapValue(event, "label"), "name"),
+		})
+	case "assigned", "unassigned":
+		details = append(details, cards.Detail{
+			Label: capitalize(action) + " user",
+			Value: StringValue(MapValue(event, "assignee"), "login"),
+		})
+	}
+	if v := strings.Join(ListStringValues(issue, "assignees", "login"), ", "); v != "" {
+		details = append(details, cards.Detail{Label: "Assignees", Value: v})
+	}
+	if v := StringValue(MapValue(issue, "milestone"), "title"); v != "" {
+		details = append(details, cards.Detail{Label: "Milestone", Value: v})
+	}
+	if v := StringValue(issue, "state_reason"); v != "" {
+		details = append(details, cards.Detail{Label: "State reason", Value: strings.ReplaceAll(v, "_", " ")})
+	}
+
+	return &cards.Message{
+		Title:         fmt.Sprintf("A issue is %s", action),
+		Subtitle:      fmt.Sprintf("Issue title: <b>%s</b>", StringValue(issue, "title")),
+		Ref:           StringValue(ghJSON, RefKey),
+		Actor:         StringValue(ghJSON, TriggeringActorKey),
+		Timestamp:     timestamp,
+		ClickURL:      StringValue(issue, eventURLKey),
+		EventName:     "issue",
+		Repo:          StringValue(ghJSON, RepositoryKey),
+		HeaderIconURL: cards.SuccessHeaderIconURL,
+		Details:       details,
+		Chips:         ListStringValues(issue, "labels", "name"),
+		Excerpt:       excerpt(StringValue(issue, "body")),
+	}
+}
+
+// discussionMessageBodyContent returns cards.Message for the discussion
+// event.
+func discussionMessageBodyContent(ghJSON, event map[string]any) *cards.Message {
+	discussion := MapValue(event, "discussion")
+	action := StringValue(event, eventActionKey)
+
+	timestamp := StringValue(discussion, "updated_at")
+	if action == "created" || timestamp == "" {
+		timestamp = StringValue(discussion, createdAtKey)
+	}
+
+	return &cards.Message{
+		Title:         fmt.Sprintf("A discussion is %s", action),
+		Subtitle:      fmt.Sprintf("Discussion title: <b>%s</b>", StringValue(discussion, "title")),
+		Ref:           StringValue(ghJSON, RefKey),
+		Actor:         StringValue(ghJSON, TriggeringActorKey),
+		Timestamp:     timestamp,
+		ClickURL:      StringValue(discussion, eventURLKey),
+		EventName:     "discussion",
+		Repo:          StringValue(ghJSON, RepositoryKey),
+		HeaderIconURL: cards.SuccessHeaderIconURL,
+		Details:       discussionDetails(discussion),
+		Excerpt:       excerpt(StringValue(discussion, "body")),
+	}
+}
+
+// discussionCommentMessageBodyContent returns cards.Message for the
+// discussion_comment event. The button links to the comment itself rather than
+// to the top of the discussion.
+func discussionCommentMessageBodyContent(ghJSON, event map[string]any) *cards.Message {
+	discussion := MapValue(event, "discussion")
+	comment := MapValue(event, "comment")
+	action := StringValue(event, eventActionKey)
+
+	timestamp := StringValue(comment, "updated_at")
+	if action == "created" || timestamp == "" {
+		timestamp = StringValue(comment, createdAtKey)
+	}
+
+	details := discussionDetails(discussion)
+	details = append(details, cards.Detail{
+		Label: "Comment author",
+		Value: StringValue(MapValue(comment, "user"), "login"),
+	})
+
+	return &cards.Message{
+		Title:         fmt.Sprintf("A discussion comment is %s", action),
+		Subtitle:      fmt.Sprintf("Discussion title: <b>%s</b>", StringValue(discussion, "title")),
+		Ref:           StringValue(ghJSON, RefKey),
+		Actor:         StringValue(ghJSON, TriggeringActorKey),
+		Timestamp:     timestamp,
+		ClickURL:      StringValue(comment, eventURLKey),
+		EventName:     "comment",
+		Repo:          StringValue(ghJSON, RepositoryKey),
+		HeaderIconURL: cards.SuccessHeaderIconURL,
+		Details:       details,
+		Excerpt:       excerpt(StringValue(comment, "body")),
+	}
+}
+
+// discussionDetails returns the category, author and answered state of a
+// discussion.
+func discussionDetails(discussion map[string]any) []cards.Detail {
+	answered := "No"
+	if StringValue(discussion, "answer_html_url") != "" {
+		answered = "Yes"
+	}
+
+	return []cards.Detail{
+		{Label: "Category", Value: StringValue(MapValue(discussion, "category"), "name")},
+		{Label: "Author", Value: StringValue(MapValue(discussion, "user"), "login")},
+		{Label: "Answered", Value: answered},
+	}
+}
+
+// refMessageBodyContent returns cards.Message for the create and delete
+// events, which GitHub sends when a branch or a tag is created or deleted. The
+// payloads carry no timestamp, so the current time is used like for workflows.
+func refMessageBodyContent(ghJSON, event map[string]any, eventName string, currentTimeStamp time.Time) *cards.Message {
+	refType := StringValue(event, "ref_type")
+	ref := StringValue(event, RefKey)
+	repoURL := githubRepoURL(ghJSON)
+
+	action := "created"
+	clickURL := fmt.Sprintf("%s/tree/%s", repoURL, ref)
+	if eventName == "delete" {
+		// The ref is gone, link to the list it was removed from instead.
+		action = "deleted"
+		clickURL = fmt.Sprintf("%s/branches", repoURL)
+		if refType == "tag" {
+			clickURL = fmt.Sprintf("%s/tags", repoURL)
+		}
+	}
+
+	return &cards.Message{
+		Title:         fmt.Sprintf("A %s is %s", refType, action),
+		Subtitle:      fmt.Sprintf("%s: <b>%s</b>", capitalize(refType), ref),
+		Ref:           StringValue(ghJSON, RefKey),
+		Actor:         StringValue(ghJSON, TriggeringActorKey),
+		Timestamp:     currentTimeStamp.UTC().Format(time.RFC3339),
+		ClickURL:      clickURL,
+		EventName:     refType,
+		Repo:          StringValue(ghJSON, RepositoryKey),
+		HeaderIconURL: cards.SuccessHeaderIconURL,
+	}
+}
+
+// CreatedTag returns the name of the tag created by the event of the github
+// context, or an empty string for any other event.
+func CreatedTag(ghJSON map[string]any) string {
+	event := MapValue(ghJSON, EventKey)
+	if StringValue(ghJSON, EventNameKey) != "create" || StringValue(event, "ref_type") != "tag" {
+		return ""
+	}
+	return StringValue(event, RefKey)
+}
+
+// AddTagCompareLink adds a button to the compare view between previousTag and
+// the newly created tag. GitHub does not include the previous tag in the create
+// payload, so it has to be provided by the caller. It is a no-op for any event
+// other than a created tag.
+func AddTagCompareLink(m *cards.Message, ghJSON map[string]any, previousTag string) {
+	tag := CreatedTag(ghJSON)
+	if previousTag == "" || tag == "" {
+		return
+	}
+
+	m.Links = append(m.Links, cards.Link{
+		Text: fmt.Sprintf("Compare with %s", previousTag),
+		URL:  fmt.Sprintf("%s/compare/%s...%s", githubRepoURL(ghJSON), previousTag, tag),
+	})
+}
+
+// releaseMessageBodyContent returns cards.Message for the release event,
+// including the release notes and the uploaded assets.
+func releaseMessageBodyContent(ghJSON, event map[string]any) *cards.Message {
+	release := MapValue(event, "release")
+
+	var details []cards.Detail
+	if v := StringValue(release, "tag_name"); v != "" {
+		details = append(details, cards.Detail{Label: "Tag", Value: v})
+	}
+	if v := StringValue(MapValue(release, "author"), "login"); v != "" {
+		details = append(details, cards.Detail{Label: "Author", Value: v})
+	}
+	assets, _ := release["assets"].([]any)
+	for _, a := range assets {
+		asset, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		size, _ := asset["size"].(float64)
+		details = append(details, cards.Detail{
+			Label:      "Asset",
+			Value:      fmt.Sprintf("%s (%s)", StringValue(asset, "name"), formatBytes(int64(size))),
+			ButtonText: "Download",
+			ButtonURL:  StringValue(asset, "browser_download_url"),
+		})
+	}
+
+	var chips []string
+	if v, _ := release["draft"].(bool); v {
+		chips = append(chips, "Draft")
+	}
+	if v, _ := release["prerelease"].(bool); v {
+		chips = append(chips, "Pre-release")
+	}
+
+	var links []cards.Link
+	if v := StringValue(release, "tarball_url"); v != "" {
+		links = append(links, cards.Link{Text: "Source (tar.gz)", URL: v})
+	}
+	if v := StringValue(release, "zipball_url"); v != "" {
+		links = append(links, cards.Link{Text: "Source (zip)", URL: v})
+	}
+
+	return &cards.Message{
+		Title:         fmt.Sprintf("A release is %s", StringValue(event, eventActionKey)),
+		Subtitle:      fmt.Sprintf("Release name: <b>%s</b>", StringValue(release, "name")),
+		Ref:           StringValue(ghJSON, RefKey),
+		Actor:         StringValue(ghJSON, TriggeringActorKey),
+		Timestamp:     StringValue(release, createdAtKey),
+		ClickURL:      StringValue(release, eventURLKey),
+		EventName:     "release",
+		Repo:          StringValue(ghJSON, RepositoryKey),
+		HeaderIconURL: cards.SuccessHeaderIconURL,
+		Details:       details,
+		Chips:         chips,
+		Excerpt:       excerpt(StringValue(release, "body")),
+		Links:         links,
+	}
+}
+
+// workflowTriggerGrid returns the grid explaining why a scheduled or manually
+// dispatched workflow ran: the cron expression or the dispatch inputs. It
+// returns nil for every other event.
+func workflowTriggerGrid(eventName string, event map[string]any) *cards.Grid {
+	switch eventName {
+	case "schedule":
+		// Other CI systems don't expose the schedule.
+		if _, ok := event["schedule"]; !ok {
+			return nil
+		}
+		return &cards.Grid{
+			Title: "Schedule",
+			Items: []cards.Detail{
+				{Label: "Cron", Value: StringValue(event, "schedule")},
+			},
+		}
+	case "workflow_dispatch":
+		inputs := MapValue(event, "inputs")
+		if len(inputs) == 0 {
+			return nil
+		}
+
+		keys := make([]string, 0, len(inputs))
+		for k := range inputs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		items := make([]cards.Detail, 0, len(keys))
+		for _, k := range keys {
+			// Inputs of type boolean and number are not strings in the payload.
+			v := ""
+			if inputs[k] != nil {
+				v = fmt.Sprint(inputs[k])
+			}
+			items = append(items, cards.Detail{Label: k, Value: v})
+		}
+		return &cards.Grid{
+			Title: "Inputs",
+			Items: items,
+		}
+	default:
+		return nil
+	}
+}
+
+// githubRepoURL returns the web URL of the repository the event belongs to.
+func githubRepoURL(ghJSON map[string]any) string {
+	return fmt.Sprintf("%s/%s", serverURL(ghJSON), StringValue(ghJSON, RepositoryKey))
+}
+
+// serverURL returns the URL of the GitHub server of the github context, which
+// is github.com for contexts without server_url.
+func serverURL(ghJSON map[string]any) string {
+	if u := StringValue(ghJSON, ServerURLKey); u != "" {
+		return u
+	}
+	return defaultServerURL
+}
+
+// capitalize upper-cases the first letter of s.
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// excerpt returns the beginning of a user provided markdown body, trimmed to
+// maxExcerptLength characters and converted to the HTML subset supported by
+// Chat cards. The body is escaped first so it can't inject card markup.
+func excerpt(s string) string {
+	s = markdownCommentRe.ReplaceAllString(s, "")
+	s = strings.TrimSpace(strings.ReplaceAll(s, "\r\n", "\n"))
+	if r := []rune(s); len(r) > maxExcerptLength {
+		s = strings.TrimSpace(string(r[:maxExcerptLength])) + "…"
+	}
+	return markdownToChat(html.EscapeString(s))
+}
+
+// markdownToChat converts the common GitHub markdown constructs of an already
+// escaped text to Chat card formatting. Anything it does not understand is
+// kept as plain text.
+func markdownToChat(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		line = markdownHeadingRe.ReplaceAllString(line, "<b>$1</b>")
+		line = markdownListRe.ReplaceAllString(line, "$1• ")
+		line = markdownLinkRe.ReplaceAllString(line, `<a href="$2">$1</a>`)
+		line = markdownBoldRe.ReplaceAllString(line, "<b>$1</b>")
+		line = markdownBoldAltRe.ReplaceAllString(line, "<b>$1</b>")
+		line = markdownItalicRe.ReplaceAllString(line, "<i>$1</i>")
+		line = markdownStrikeRe.ReplaceAllString(line, "<s>$1</s>")
+		line = markdownCodeRe.ReplaceAllString(line, `<font color="#6a737d">$1</font>`)
+		lines[i] = line
+	}
+	return strings.Join(lines, "<br>")
+}
+
+// formatBytes returns a human readable size, e.g. 1.5 MB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,748 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMessageContent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name      string
+		ghJSON    map[string]any
+		jobJSON   map[string]any
+		timestamp time.Time
+		want      *cards.Message
+	}{
+		{
+			name: "issue_labeled",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"event_name": "issues",
+				"event": map[string]any{
+					"action": "labeled",
+					"label": map[string]any{
+						"name": "bug",
+					},
+					"issue": map[string]any{
+						"title":      "test-title",
+						"body":       "It **crashes**",
+						"html_url":   "https://foo.com/issues/1",
+						"created_at": "2023-04-25T17:44:57Z",
+						"updated_at": "2023-04-26T17:44:57Z",
+						"labels": []any{
+							map[string]any{"name": "bug"},
+							map[string]any{"name": "p1"},
+						},
+						"assignees": []any{
+							map[string]any{"login": "alice"},
+							map[string]any{"login": "bob"},
+						},
+						"milestone": map[string]any{
+							"title": "v1.0",
+						},
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A issue is labeled",
+				Subtitle:      "Issue title: <b>test-title</b>",
+				Timestamp:     "2023-04-26T17:44:57Z",
+				ClickURL:      "https://foo.com/issues/1",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "issue",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Labeled label", Value: "bug"},
+					{Label: "Assignees", Value: "alice, bob"},
+					{Label: "Milestone", Value: "v1.0"},
+				},
+				Chips:   []string{"bug", "p1"},
+				Excerpt: "It <b>crashes</b>",
+			},
+		},
+		{
+			name: "issue_assigned",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"event_name": "issues",
+				"event": map[string]any{
+					"action": "assigned",
+					"assignee": map[string]any{
+						"login": "alice",
+					},
+					"issue": map[string]any{
+						"title":      "test-title",
+						"html_url":   "https://foo.com/issues/1",
+						"created_at": "2023-04-25T17:44:57Z",
+						"assignees": []any{
+							map[string]any{"login": "alice"},
+						},
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A issue is assigned",
+				Subtitle:      "Issue title: <b>test-title</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://foo.com/issues/1",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "issue",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Assigned user", Value: "alice"},
+					{Label: "Assignees", Value: "alice"},
+				},
+			},
+		},
+		{
+			name: "issue_closed",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"event_name": "issues",
+				"event": map[string]any{
+					"action": "closed",
+					"issue": map[string]any{
+						"title":        "test-title",
+						"html_url":     "https://foo.com/issues/1",
+						"created_at":   "2023-04-25T17:44:57Z",
+						"updated_at":   "2023-04-27T17:44:57Z",
+						"closed_at":    "2023-04-27T17:44:57Z",
+						"state_reason": "not_planned",
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A issue is closed",
+				Subtitle:      "Issue title: <b>test-title</b>",
+				Timestamp:     "2023-04-27T17:44:57Z",
+				ClickURL:      "https://foo.com/issues/1",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "issue",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "State reason", Value: "not planned"},
+				},
+			},
+		},
+		{
+			name: "discussion_created",
+			ghJSON: map[string]any{
+				"ref":              "test-ref",
+				"triggering_actor": "test-triggered_actor",
+				"repository":       "test-repository",
+				"event_name":       "discussion",
+				"event": map[string]any{
+					"action": "created",
+					"discussion": map[string]any{
+						"title":      "test-title",
+						"body":       "How do I <configure> this?",
+						"html_url":   "https://foo.com/discussions/1",
+						"created_at": "2023-04-25T17:44:57Z",
+						"updated_at": "2023-04-26T17:44:57Z",
+						"category": map[string]any{
+							"name": "Q&A",
+						},
+						"user": map[string]any{
+							"login": "test-author",
+						},
+						"answer_html_url": nil,
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A discussion is created",
+				Subtitle:      "Discussion title: <b>test-title</b>",
+				Ref:           "test-ref",
+				Actor:         "test-triggered_actor",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://foo.com/discussions/1",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "discussion",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Category", Value: "Q&A"},
+					{Label: "Author", Value: "test-author"},
+					{Label: "Answered", Value: "No"},
+				},
+				Excerpt: "How do I &lt;configure&gt; this?",
+			},
+		},
+		{
+			name: "discussion_answered",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"event_name": "discussion",
+				"event": map[string]any{
+					"action": "answered",
+					"discussion": map[string]any{
+						"title":           "test-title",
+						"html_url":        "https://foo.com/discussions/1",
+						"created_at":      "2023-04-25T17:44:57Z",
+						"updated_at":      "2023-04-26T17:44:57Z",
+						"answer_html_url": "https://foo.com/discussions/1#discussioncomment-2",
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A discussion is answered",
+				Subtitle:      "Discussion title: <b>test-title</b>",
+				Timestamp:     "2023-04-26T17:44:57Z",
+				ClickURL:      "https://foo.com/discussions/1",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "discussion",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Category", Value: ""},
+					{Label: "Author", Value: ""},
+					{Label: "Answered", Value: "Yes"},
+				},
+			},
+		},
+		{
+			name: "discussion_comment_created",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"event_name": "discussion_comment",
+				"event": map[string]any{
+					"action": "created",
+					"discussion": map[string]any{
+						"title": "test-title",
+						"category": map[string]any{
+							"name": "Ideas",
+						},
+						"user": map[string]any{
+							"login": "test-author",
+						},
+					},
+					"comment": map[string]any{
+						"body":       "  Sounds good  ",
+						"html_url":   "https://foo.com/discussions/1#discussioncomment-2",
+						"created_at": "2023-04-25T17:44:57Z",
+						"user": map[string]any{
+							"login": "test-commenter",
+						},
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A discussion comment is created",
+				Subtitle:      "Discussion title: <b>test-title</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://foo.com/discussions/1#discussioncomment-2",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "comment",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Category", Value: "Ideas"},
+					{Label: "Author", Value: "test-author"},
+					{Label: "Answered", Value: "No"},
+					{Label: "Comment author", Value: "test-commenter"},
+				},
+				Excerpt: "Sounds good",
+			},
+		},
+		{
+			name: "tag_created",
+			ghJSON: map[string]any{
+				"ref":              "refs/tags/v1.2.3",
+				"triggering_actor": "test-triggered_actor",
+				"repository":       "test-repository",
+				"server_url":       "https://github.com",
+				"event_name":       "create",
+				"event": map[string]any{
+					"ref":      "v1.2.3",
+					"ref_type": "tag",
+				},
+			},
+			jobJSON:   map[string]any{},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "A tag is created",
+				Subtitle:      "Tag: <b>v1.2.3</b>",
+				Ref:           "refs/tags/v1.2.3",
+				Actor:         "test-triggered_actor",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/tree/v1.2.3",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "tag",
+				Repo:          "test-repository",
+			},
+		},
+		{
+			name: "branch_deleted",
+			ghJSON: map[string]any{
+				"ref":              "refs/heads/main",
+				"triggering_actor": "test-triggered_actor",
+				"repository":       "test-repository",
+				"server_url":       "https://github.com",
+				"event_name":       "delete",
+				"event": map[string]any{
+					"ref":      "feature/foo",
+					"ref_type": "branch",
+				},
+			},
+			jobJSON:   map[string]any{},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "A branch is deleted",
+				Subtitle:      "Branch: <b>feature/foo</b>",
+				Ref:           "refs/heads/main",
+				Actor:         "test-triggered_actor",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/branches",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "branch",
+				Repo:          "test-repository",
+			},
+		},
+		{
+			name: "scheduled_workflow",
+			ghJSON: map[string]any{
+				"workflow":   "nightly",
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"run_id":     "test-run-id",
+				"event_name": "schedule",
+				"event": map[string]any{
+					"schedule": "0 3 * * *",
+				},
+			},
+			jobJSON: map[string]any{
+				"status": "success",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow success",
+				Subtitle:      "Workflow: <b>nightly</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+				Grid: &cards.Grid{
+					Title: "Schedule",
+					Items: []cards.Detail{
+						{Label: "Cron", Value: "0 3 * * *"},
+					},
+				},
+			},
+		},
+		{
+			name: "dispatched_workflow",
+			ghJSON: map[string]any{
+				"workflow":   "deploy",
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"run_id":     "test-run-id",
+				"event_name": "workflow_dispatch",
+				"event": map[string]any{
+					"inputs": map[string]any{
+						"environment": "staging",
+						"dry_run":     true,
+						"replicas":    float64(3),
+						"note":        nil,
+					},
+				},
+			},
+			jobJSON: map[string]any{
+				"status": "failure",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow failure",
+				Subtitle:      "Workflow: <b>deploy</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.FailureHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+				Grid: &cards.Grid{
+					Title: "Inputs",
+					Items: []cards.Detail{
+						{Label: "dry_run", Value: "true"},
+						{Label: "environment", Value: "staging"},
+						{Label: "note", Value: ""},
+						{Label: "replicas", Value: "3"},
+					},
+				},
+			},
+		},
+		{
+			name: "workflow_succeeded_on_retry",
+			ghJSON: map[string]any{
+				"workflow":    "ci",
+				"repository":  "test-repository",
+				"server_url":  "https://github.com",
+				"run_id":      "test-run-id",
+				"run_attempt": "2",
+			},
+			jobJSON: map[string]any{
+				"status": "success",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow succeeded on retry",
+				Subtitle:      "Workflow: <b>ci</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Attempt", Value: "2"},
+				},
+			},
+		},
+		{
+			name: "workflow_failed_first_attempt",
+			ghJSON: map[string]any{
+				"workflow":    "ci",
+				"repository":  "test-repository",
+				"server_url":  "https://github.com",
+				"run_id":      "test-run-id",
+				"run_attempt": "1",
+			},
+			jobJSON: map[string]any{
+				"status": "failure",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow failure",
+				Subtitle:      "Workflow: <b>ci</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.FailureHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Attempt", Value: "1"},
+				},
+			},
+		},
+		{
+			name: "workflow_cancelled",
+			ghJSON: map[string]any{
+				"workflow":   "ci",
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"run_id":     "test-run-id",
+			},
+			jobJSON: map[string]any{
+				"status": "cancelled",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow cancelled",
+				Subtitle:      "Workflow: <b>ci</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.FailureHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+			},
+		},
+		{
+			name: "workflow_timed_out",
+			ghJSON: map[string]any{
+				"workflow":   "ci",
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"run_id":     "test-run-id",
+			},
+			jobJSON: map[string]any{
+				"status": "timed_out",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow timed_out",
+				Subtitle:      "Workflow: <b>ci</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.FailureHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+			},
+		},
+		{
+			name: "dispatched_workflow_without_inputs",
+			ghJSON: map[string]any{
+				"workflow":   "deploy",
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"run_id":     "test-run-id",
+				"event_name": "workflow_dispatch",
+				"event":      map[string]any{},
+			},
+			jobJSON: map[string]any{
+				"status": "success",
+			},
+			timestamp: time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC),
+			want: &cards.Message{
+				Title:         "GitHub workflow success",
+				Subtitle:      "Workflow: <b>deploy</b>",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://github.com/test-repository/actions/runs/test-run-id",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "workflow",
+				Repo:          "test-repository",
+			},
+		},
+		{
+			name: "release_published",
+			ghJSON: map[string]any{
+				"ref":              "refs/tags/v1.2.3",
+				"triggering_actor": "test-triggered_actor",
+				"repository":       "test-repository",
+				"event_name":       "release",
+				"event": map[string]any{
+					"action": "published",
+					"release": map[string]any{
+						"name":        "test-title",
+						"tag_name":    "v1.2.3",
+						"created_at":  "2023-04-25T17:44:57Z",
+						"html_url":    "https://foo.com/releases/v1.2.3",
+						"body":        "## Changes\r\n- **New** flag\r\n<!-- hidden -->",
+						"draft":       false,
+						"prerelease":  true,
+						"tarball_url": "https://foo.com/tarball/v1.2.3",
+						"zipball_url": "https://foo.com/zipball/v1.2.3",
+						"author": map[string]any{
+							"login": "test-author",
+						},
+						"assets": []any{
+							map[string]any{
+								"name":                 "tool_linux_amd64.tar.gz",
+								"size":                 float64(1572864),
+								"browser_download_url": "https://foo.com/download/tool_linux_amd64.tar.gz",
+							},
+						},
+					},
+				},
+			},
+			jobJSON: map[string]any{},
+			want: &cards.Message{
+				Title:         "A release is published",
+				Subtitle:      "Release name: <b>test-title</b>",
+				Ref:           "refs/tags/v1.2.3",
+				Actor:         "test-triggered_actor",
+				Timestamp:     "2023-04-25T17:44:57Z",
+				ClickURL:      "https://foo.com/releases/v1.2.3",
+				HeaderIconURL: cards.SuccessHeaderIconURL,
+				EventName:     "release",
+				Repo:          "test-repository",
+				Details: []cards.Detail{
+					{Label: "Tag", Value: "v1.2.3"},
+					{Label: "Author", Value: "test-author"},
+					{
+						Label:      "Asset",
+						Value:      "tool_linux_amd64.tar.gz (1.5 MB)",
+						ButtonText: "Download",
+						ButtonURL:  "https://foo.com/download/tool_linux_amd64.tar.gz",
+					},
+				},
+				Chips:   []string{"Pre-release"},
+				Excerpt: "<b>Changes</b><br>• <b>New</b> flag",
+				Links: []cards.Link{
+					{Text: "Source (tar.gz)", URL: "https://foo.com/tarball/v1.2.3"},
+					{Text: "Source (zip)", URL: "https://foo.com/zipball/v1.2.3"},
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := MessageContent(tc.ghJSON, tc.jobJSON, tc.timestamp)
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(cards.Message{}, cards.Detail{}, cards.Grid{}, cards.Link{})); diff != "" {
+				t.Errorf("cards.Message got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestAddTagCompareLink(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		ghJSON      map[string]any
+		previousTag string
+		want        []cards.Link
+	}{
+		{
+			name: "created_tag",
+			ghJSON: map[string]any{
+				"repository": "test-repository",
+				"server_url": "https://github.com",
+				"event_name": "create",
+				"event": map[string]any{
+					"ref":      "v1.2.3",
+					"ref_type": "tag",
+				},
+			},
+			previousTag: "v1.2.2",
+			want: []cards.Link{
+				{Text: "Compare with v1.2.2", URL: "https://github.com/test-repository/compare/v1.2.2...v1.2.3"},
+			},
+		},
+		{
+			name: "no_previous_tag",
+			ghJSON: map[string]any{
+				"event_name": "create",
+				"event": map[string]any{
+					"ref":      "v1.2.3",
+					"ref_type": "tag",
+				},
+			},
+		},
+		{
+			name: "created_branch",
+			ghJSON: map[string]any{
+				"event_name": "create",
+				"event": map[string]any{
+					"ref":      "main",
+					"ref_type": "branch",
+				},
+			},
+			previousTag: "v1.2.2",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &cards.Message{}
+			AddTagCompareLink(m, tc.ghJSON, tc.previousTag)
+			if diff := cmp.Diff(tc.want, m.Links, cmp.AllowUnexported(cards.Link{})); diff != "" {
+				t.Errorf("links got unexpected diff (-want, +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "empty",
+			in:   "",
+			want: "",
+		},
+		{
+			name: "escapes_markup",
+			in:   "<b>bold</b> & more\r\n",
+			want: "&lt;b&gt;bold&lt;/b&gt; &amp; more",
+		},
+		{
+			name: "truncates",
+			in:   strings.Repeat("a", maxExcerptLength+10),
+			want: strings.Repeat("a", maxExcerptLength) + "…",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := excerpt(tc.in), tc.want; got != want {
+				t.Errorf("excerpt(%q) got %q, want %q", tc.in, got, want)
+			}
+		})
+	}
+}
+
+func TestMarkdownToChat(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "plain",
+			in:   "nothing to see here",
+			want: "nothing to see here",
+		},
+		{
+			name: "headings_and_lists",
+			in:   "# Title\n* one\n  - two",
+			want: "<b>Title</b><br>• one<br>  • two",
+		},
+		{
+			name: "inline",
+			in:   "**bold** __bold__ *italic* ~~gone~~ `code` snake_case_name",
+			want: `<b>bold</b> <b>bold</b> <i>italic</i> <s>gone</s> <font color="#6a737d">code</font> snake_case_name`,
+		},
+		{
+			name: "links",
+			in:   "see [the docs](https://foo.com/docs?a=1&amp;b=2)",
+			want: `see <a href="https://foo.com/docs?a=1&amp;b=2">the docs</a>`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got, want := markdownToChat(tc.in), tc.want; got != want {
+				t.Errorf("markdownToChat(%q) got %q, want %q", tc.in, got, want)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in   int64
+		want string
+	}{
+		{in: 0, want: "0 B"},
+		{in: 1023, want: "1023 B"},
+		{in: 1536, want: "1.5 KB"},
+		{in: 1572864, want: "1.5 MB"},
+		{in: 3 << 30, want: "3.0 GB"},
+	}
+
+	for _, tc := range cases {
+		if got, want := formatBytes(tc.in), tc.want; got != want {
+			t.Errorf("formatBytes(%d) got %q, want %q", tc.in, got, want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/githubevents"
+)
+
+func ExampleMessageContent() {
+	ghJSON := map[string]any{
+		"event_name":       "push",
+		"workflow":         "ci",
+		"ref":              "refs/heads/main",
+		"triggering_actor": "octocat",
+		"repository":       "org/repo",
+		"run_id":           "1",
+		"server_url":       "https://github.com",
+	}
+	jobJSON := map[string]any{
+		"status": "failure",
+	}
+
+	m := githubevents.MessageContent(ghJSON, jobJSON, time.Date(2023, time.April, 25, 17, 44, 57, 0, time.UTC))
+	fmt.Println(m.Title)
+	fmt.Println(m.ClickURL)
+	// Output:
+	// GitHub workflow failure
+	// https://github.com/org/repo/actions/runs/1
+}
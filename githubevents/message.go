@@ -0,0 +1,141 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package githubevents renders the github context of GitHub Actions, and of the
+// webhook events of GitHub, as cards.Message.
+package githubevents
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+)
+
+// Keys of the github context.
+const (
+	RefKey             = "ref"
+	RepositoryKey      = "repository"
+	TriggeringActorKey = "triggering_actor"
+	eventActionKey     = "action"
+	EventNameKey       = "event_name"
+	EventKey           = "event"
+	eventURLKey        = "html_url"
+	createdAtKey       = "created_at"
+	ServerURLKey       = "server_url"
+)
+
+// defaultServerURL is the URL of github.com, used when the github context has
+// no server_url.
+const defaultServerURL = "https://github.com"
+
+// RunURLKey is the key of the URL of the run. It is not part of
+// the github context of GitHub Actions, where the URL is derived from the run
+// id, and is set by the providers of other CI systems and for the webhook
+// deliveries of jobs and checks.
+const RunURLKey = "run_url"
+
+// MessageContent returns the message for the event of the github context, or
+// for the workflow run with the status of the job context when the event has no
+// dedicated renderer. currentTimeStamp is the time shown for workflow runs and
+// refs, as the payloads of these events have no common timestamp.
+func MessageContent(ghJSON, jobJSON map[string]any, currentTimeStamp time.Time) *cards.Message {
+	event, ok := ghJSON[EventKey].(map[string]any)
+	if !ok {
+		event = map[string]any{}
+	}
+	eventName := StringValue(ghJSON, EventNameKey)
+	switch eventName {
+	case "issues":
+		return issueMessageBodyContent(ghJSON, event)
+	case "discussion":
+		return discussionMessageBodyContent(ghJSON, event)
+	case "discussion_comment":
+		return discussionCommentMessageBodyContent(ghJSON, event)
+	case "create", "delete":
+		return refMessageBodyContent(ghJSON, event, eventName, currentTimeStamp)
+	case "release":
+		return releaseMessageBodyContent(ghJSON, event)
+	default:
+		res := &cards.Message{
+			Title:    fmt.Sprintf("GitHub workflow %s", StringValue(jobJSON, "status")),
+			Subtitle: fmt.Sprintf("Workflow: <b>%s</b>", StringValue(ghJSON, "workflow")),
+			Ref:      StringValue(ghJSON, RefKey),
+			Actor:    StringValue(ghJSON, TriggeringActorKey),
+			// The key for getting timestamp is different in differnet triggering event
+			// a simple work around is using the new timestamp.
+			Timestamp: currentTimeStamp.UTC().Format(time.RFC3339),
+			ClickURL:  WorkflowRunURL(ghJSON),
+			EventName: "workflow",
+			Repo:      StringValue(ghJSON, RepositoryKey),
+			Grid:      workflowTriggerGrid(eventName, event),
+		}
+		v, ok := jobJSON["status"].(string)
+		if !ok || IsFailedStatus(v) {
+			res.HeaderIconURL = cards.FailureHeaderIconURL
+		} else {
+			res.HeaderIconURL = cards.SuccessHeaderIconURL
+		}
+		if attempt := RunAttempt(ghJSON); attempt > 0 {
+			res.Details = append(res.Details, cards.Detail{Label: "Attempt", Value: strconv.Itoa(attempt)})
+			if attempt > 1 && v == "success" {
+				res.Title = "GitHub workflow succeeded on retry"
+			}
+		}
+		return res
+	}
+}
+
+// StringValue get value from a map[sting]any map.
+// And convert it into string type. Return empty if the conversion failed.
+// The keys should all exist as they are popluated by github, to simple the
+// code on unnecessary error handling, a empty string is returned.
+func StringValue(m map[string]any, key string) string {
+	v, ok := m[key].(string)
+	if !ok {
+		v = ""
+	}
+	return v
+}
+
+// ListStringValues get the string value of field from every object of
+// the list stored under key, e.g. the names of the labels of an issue. Entries
+// that are not objects or have no such field are skipped.
+func ListStringValues(m map[string]any, key, field string) []string {
+	list, _ := m[key].([]any)
+
+	var res []string
+	for _, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if v := StringValue(obj, field); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// MapValue get a nested object from a map[string]any map. Return an
+// empty map if the key is missing or is not an object, so callers can keep
+// reading fields from it without extra checks.
+func MapValue(m map[string]any, key string) map[string]any {
+	v, ok := m[key].(map[string]any)
+	if !ok {
+		v = map[string]any{}
+	}
+	return v
+}
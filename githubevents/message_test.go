@@ -12,7 +12,7 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-package main
+package githubevents
 
 import (
 	"encoding/json"
@@ -20,10 +20,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
 	"github.com/google/go-cmp/cmp"
 )
 
-func TestGenerateRequestBody(t *testing.T) {
+func TestMessageContent_RequestBody(t *testing.T) {
 	t.Parallel()
 
 	cases := []struct {
@@ -64,7 +65,7 @@ func TestGenerateRequestBody(t *testing.T) {
 									{
 										"decoratedText": map[string]any{
 											"startIcon": map[string]any{
-												"iconUrl": widgetRefIconURL,
+												"iconUrl": cards.WidgetRefIconURL,
 											},
 											"text": fmt.Sprintf("<b>Repo: </b> %s", "test-repository"),
 										},
@@ -145,7 +146,7 @@ func TestGenerateRequestBody(t *testing.T) {
 									{
 										"decoratedText": map[string]any{
 											"startIcon": map[string]any{
-												"iconUrl": widgetRefIconURL,
+												"iconUrl": cards.WidgetRefIconURL,
 											},
 											"text": fmt.Sprintf("<b>Repo: </b> %s", "test-repository"),
 										},
@@ -232,7 +233,7 @@ func TestGenerateRequestBody(t *testing.T) {
 									{
 										"decoratedText": map[string]any{
 											"startIcon": map[string]any{
-												"iconUrl": widgetRefIconURL,
+												"iconUrl": cards.WidgetRefIconURL,
 											},
 											"text": fmt.Sprintf("<b>Repo: </b> %s", "test-repository"),
 										},
@@ -318,7 +319,7 @@ func TestGenerateRequestBody(t *testing.T) {
 									{
 										"decoratedText": map[string]any{
 											"startIcon": map[string]any{
-												"iconUrl": widgetRefIconURL,
+												"iconUrl": cards.WidgetRefIconURL,
 											},
 											"text": fmt.Sprintf("<b>Repo: </b> %s", "test-repository"),
 										},
@@ -373,7 +374,7 @@ func TestGenerateRequestBody(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
-			gotMessageBody, err := generateRequestBody(generateMessageBodyContent(tc.ghJSON, tc.jobJSON, tc.timestamp))
+			gotMessageBody, err := cards.RequestBody(MessageContent(tc.ghJSON, tc.jobJSON, tc.timestamp))
 			if err != nil {
 				t.Fatalf("failed to generate messag body %v", err)
 			}
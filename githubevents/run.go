@@ -0,0 +1,50 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package githubevents
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// IsFailedStatus returns true for job statuses and run conclusions that mean
+// the run did not succeed.
+func IsFailedStatus(s string) bool {
+	switch s {
+	case "failure", "cancelled", "canceled", "timed_out", "startup_failure":
+		return true
+	default:
+		return false
+	}
+}
+
+// RunAttempt returns the attempt number of the run, starting at 1 and
+// increasing with every rerun. It returns 0 if unknown.
+func RunAttempt(ghJSON map[string]any) int {
+	attempt, err := strconv.Atoi(StringValue(ghJSON, "run_attempt"))
+	if err != nil {
+		return 0
+	}
+	return attempt
+}
+
+// WorkflowRunURL returns the URL of the run, as set by the providers of other
+// CI systems or else derived from the run id.
+func WorkflowRunURL(ghJSON map[string]any) string {
+	if u := StringValue(ghJSON, RunURLKey); u != "" {
+		return u
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", serverURL(ghJSON), StringValue(ghJSON, RepositoryKey), StringValue(ghJSON, "run_id"))
+}
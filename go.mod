@@ -7,6 +7,7 @@ toolchain go1.24.5
 require (
 	github.com/abcxyz/pkg v1.5.4
 	github.com/google/go-cmp v0.7.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
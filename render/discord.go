@@ -0,0 +1,137 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+// Limits of Discord embeds.
+const (
+	discordMaxTitleLength       = 256
+	discordMaxDescriptionLength = 4096
+	discordMaxFields            = 25
+	discordMaxFieldNameLength   = 256
+	discordMaxFieldValueLength  = 1024
+	discordMaxContentLength     = 2000
+)
+
+// discordColors are the embed colors per header icon, i.e. per status.
+var discordColors = map[string]int{
+	cards.SuccessHeaderIconURL: 0x2ea44f,
+	cards.FailureHeaderIconURL: 0xcb2431,
+	cards.RunningHeaderIconURL: 0xdbab09,
+}
+
+// Discord sends embeds to Discord webhooks.
+type Discord struct{}
+
+func (Discord) Name() string {
+	return "discord"
+}
+
+func (Discord) RequestBody(m *cards.Message) ([]byte, error) {
+	var description []string
+	if m.Subtitle != "" {
+		description = append(description, markdownStyle.format(m.Subtitle))
+	}
+	if m.Excerpt != "" {
+		description = append(description, "> "+strings.ReplaceAll(markdownStyle.format(m.Excerpt), "\n", "\n> "))
+	}
+	if len(m.Chips) > 0 {
+		chips := make([]string, 0, len(m.Chips))
+		for _, c := range m.Chips {
+			chips = append(chips, "`"+plainStyle.format(c)+"`")
+		}
+		description = append(description, strings.Join(chips, " "))
+	}
+	// Webhook messages can't have link buttons, so the links are part of the
+	// description.
+	links := make([]string, 0, len(m.Links))
+	for _, l := range m.Links {
+		links = append(links, fmt.Sprintf("[%s](%s)", l.Text, l.URL))
+	}
+	if len(links) > 0 {
+		description = append(description, strings.Join(links, " · "))
+	}
+
+	fields := cards.Fields(m)
+	if m.Grid != nil {
+		fields = append(fields, m.Grid.Items...)
+	}
+	embedFields := make([]any, 0, min(len(fields), discordMaxFields))
+	for _, f := range fields[:min(len(fields), discordMaxFields)] {
+		value := markdownStyle.format(f.Value)
+		if f.ButtonURL != "" {
+			value = fmt.Sprintf("%s [%s](%s)", value, f.ButtonText, f.ButtonURL)
+		}
+		if strings.TrimSpace(value) == "" {
+			// Fields can't be empty.
+			value = "-"
+		}
+		embedFields = append(embedFields, map[string]any{
+			"name":   truncate(plainStyle.format(f.Label), discordMaxFieldNameLength),
+			"value":  truncate(value, discordMaxFieldValueLength),
+			"inline": true,
+		})
+	}
+
+	embed := map[string]any{
+		"title":  truncate(plainStyle.format(m.Title), discordMaxTitleLength),
+		"url":    m.ClickURL,
+		"fields": embedFields,
+	}
+	if len(description) > 0 {
+		embed["description"] = truncate(strings.Join(description, "\n\n"), discordMaxDescriptionLength)
+	}
+	if color, ok := discordColors[m.HeaderIconURL]; ok {
+		embed["color"] = color
+	}
+	if m.HeaderIconURL != "" {
+		embed["thumbnail"] = map[string]any{
+			"url": m.HeaderIconURL,
+		}
+	}
+
+	body := map[string]any{
+		"embeds": []any{embed},
+	}
+	if text := strings.TrimSpace(markdownStyle.format(m.Text)); text != "" {
+		body["content"] = truncate(text, discordMaxContentLength)
+	}
+	res, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshal jsonData: %w", err)
+	}
+	return res, nil
+}
+
+// WithIdempotencyKey returns u unchanged, Discord webhooks can't deduplicate
+// messages.
+func (Discord) WithIdempotencyKey(u, key string) (string, error) {
+	return u, nil
+}
+
+func (Discord) Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error) {
+	statusCode, err := postJSON(ctx, client, u, body, nil)
+	return statusCode, nil, err
+}
@@ -0,0 +1,40 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"fmt"
+
+	"github.com/google-github-actions/send-google-chat-webhook/render"
+)
+
+func ExampleParseWebhookURL() {
+	ns := render.Notifiers()
+	for _, raw := range []string{
+		"https://chat.googleapis.com/v1/spaces/AAAA/messages?key=KEY&token=TOKEN",
+		"https://hooks.slack.com/services/T000/B000/XXXX",
+		"json+https://example.com/hooks/incidents",
+	} {
+		n, u, err := render.ParseWebhookURL(raw, ns)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Println(n.Name(), u)
+	}
+	// Output:
+	// googlechat https://chat.googleapis.com/v1/spaces/AAAA/messages?key=KEY&token=TOKEN
+	// slack https://hooks.slack.com/services/T000/B000/XXXX
+	// json https://example.com/hooks/incidents
+}
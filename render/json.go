@@ -0,0 +1,236 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+// JSONSignatureHeader is the header holding the signature of the request
+// body, in the format of GitHub's X-Hub-Signature-256 so that receivers can
+// verify it the same way.
+const JSONSignatureHeader = "X-Signature-256"
+
+// defaultJSONTemplate renders the whole template data, without the github and
+// job contexts which templates have to opt into.
+var defaultJSONTemplate = template.Must(NewJSONTemplate(`{{ json . }}`))
+
+// githubContextFields are the fields of the github context exposed to
+// templates. Other fields, e.g. token, never reach templates, so that fields
+// added to the context later can't leak secrets.
+// https://docs.github.com/en/actions/learn-github-actions/contexts#github-context
+var githubContextFields = []string{
+	"action", "actor", "actor_id", "api_url", "base_ref", "event", "event_name",
+	"graphql_url", "head_ref", "job", "ref", "ref_name", "ref_protected",
+	"ref_type", "repository", "repository_id", "repository_owner",
+	"repository_owner_id", "repositoryUrl", "run_attempt", "run_id", "run_number",
+	"server_url", "sha", "triggering_actor", "workflow", "workflow_ref",
+	"workflow_sha",
+}
+
+// jobContextFields are the fields of the job context exposed to templates.
+// https://docs.github.com/en/actions/learn-github-actions/contexts#job-context
+var jobContextFields = []string{"status", "check_run_id", "container", "services"}
+
+// RunContext is the status and the contexts a message was generated from,
+// which are only available to templates of the JSON backend.
+type RunContext struct {
+	Status string
+	GitHub map[string]any
+	Job    map[string]any
+}
+
+// jsonField is a labeled row of the message in the template data.
+type jsonField struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+	URL   string `json:"url,omitempty"`
+}
+
+// jsonLink is a link of the message in the template data.
+type jsonLink struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// jsonTemplateData is the data JSON backend templates are rendered with. Text
+// is plain, without the formatting of Chat cards.
+type jsonTemplateData struct {
+	Title     string         `json:"title"`
+	Subtitle  string         `json:"subtitle"`
+	Text      string         `json:"text,omitempty"`
+	Repo      string         `json:"repo"`
+	Ref       string         `json:"ref"`
+	Actor     string         `json:"actor"`
+	Timestamp string         `json:"timestamp"`
+	EventName string         `json:"event_name"`
+	Status    string         `json:"status,omitempty"`
+	URL       string         `json:"url"`
+	Fields    []*jsonField   `json:"fields"`
+	Labels    []string       `json:"labels,omitempty"`
+	Excerpt   string         `json:"excerpt,omitempty"`
+	Links     []*jsonLink    `json:"links,omitempty"`
+	GitHub    map[string]any `json:"-"`
+	Job       map[string]any `json:"-"`
+}
+
+// newJSONTemplateData returns the template data of m generated from rc, which
+// may be nil. Only the exposed fields of the contexts are kept.
+func newJSONTemplateData(m *cards.Message, rc *RunContext) *jsonTemplateData {
+	d := &jsonTemplateData{
+		Title:     plainStyle.format(m.Title),
+		Subtitle:  plainStyle.format(m.Subtitle),
+		Text:      strings.TrimSpace(plainStyle.format(m.Text)),
+		Repo:      m.Repo,
+		Ref:       m.Ref,
+		Actor:     m.Actor,
+		Timestamp: m.Timestamp,
+		EventName: m.EventName,
+		URL:       m.ClickURL,
+		Labels:    m.Chips,
+		Excerpt:   plainStyle.format(m.Excerpt),
+		GitHub:    map[string]any{},
+		Job:       map[string]any{},
+	}
+	if rc != nil {
+		d.Status = rc.Status
+		d.GitHub = exposedFields(rc.GitHub, githubContextFields)
+		d.Job = exposedFields(rc.Job, jobContextFields)
+	}
+	fields := cards.Fields(m)
+	if m.Grid != nil {
+		fields = append(fields, m.Grid.Items...)
+	}
+	for _, f := range fields {
+		d.Fields = append(d.Fields, &jsonField{
+			Label: plainStyle.format(f.Label),
+			Value: plainStyle.format(f.Value),
+			URL:   f.ButtonURL,
+		})
+	}
+	for _, l := range m.Links {
+		d.Links = append(d.Links, &jsonLink{Text: l.Text, URL: l.URL})
+	}
+	return d
+}
+
+// exposedFields returns a copy of the context v holding only the given fields.
+func exposedFields(v map[string]any, fields []string) map[string]any {
+	out := make(map[string]any, len(fields))
+	for _, k := range fields {
+		if val, ok := v[k]; ok {
+			out[k] = val
+		}
+	}
+	return out
+}
+
+// WithRunContext returns a copy of ns whose JSON backend renders templates
+// with rc. ns is not modified.
+func WithRunContext(ns map[string]Notifier, rc *RunContext) map[string]Notifier {
+	out := make(map[string]Notifier, len(ns))
+	for name, n := range ns {
+		if j, ok := n.(*JSON); ok {
+			cp := *j
+			cp.Run = rc
+			n = &cp
+		}
+		out[name] = n
+	}
+	return out
+}
+
+// NewJSONTemplate parses the text/template of the request body. The json
+// function encodes a value as JSON, e.g. {"title": {{ json .Title }}}.
+func NewJSONTemplate(text string) (*template.Template, error) {
+	return template.New("json").
+		Option("missingkey=error").
+		Funcs(template.FuncMap{
+			"json": func(v any) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).
+		Parse(text)
+}
+
+// JSON posts a templated JSON body to any webhook, e.g. of incident
+// tooling. It is only used with the json+ prefix of webhook urls.
+type JSON struct {
+	// Template renders the request body, defaultJSONTemplate if nil.
+	Template *template.Template
+	Header   http.Header
+	// Secret signs the request body when set.
+	Secret []byte
+	// Run is the context the messages are generated from, see WithRunContext.
+	Run *RunContext
+}
+
+func (*JSON) Name() string {
+	return "json"
+}
+
+func (n *JSON) RequestBody(m *cards.Message) ([]byte, error) {
+	tmpl := n.Template
+	if tmpl == nil {
+		tmpl = defaultJSONTemplate
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, newJSONTemplateData(m, n.Run)); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	var body bytes.Buffer
+	if err := json.Compact(&body, b.Bytes()); err != nil {
+		return nil, fmt.Errorf("template did not render valid JSON: %w", err)
+	}
+	return body.Bytes(), nil
+}
+
+// WithIdempotencyKey returns u unchanged, as the receiver is unknown.
+func (*JSON) WithIdempotencyKey(u, key string) (string, error) {
+	return u, nil
+}
+
+func (n *JSON) Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error) {
+	header := n.Header.Clone()
+	if len(n.Secret) > 0 {
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set(JSONSignatureHeader, jsonSignature(n.Secret, body))
+	}
+	statusCode, err := postJSON(ctx, client, u, body, header)
+	return statusCode, nil, err
+}
+
+// jsonSignature returns the sha256=<hex> HMAC of body with secret.
+func jsonSignature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
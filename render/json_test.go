@@ -0,0 +1,186 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestJSON_RequestBody(t *testing.T) {
+	t.Parallel()
+
+	m := &cards.Message{
+		Title:     "GitHub workflow failure",
+		Subtitle:  "Workflow: <b>ci</b>",
+		Ref:       "refs/heads/main",
+		Actor:     "octocat",
+		Timestamp: "2023-01-02T03:04:05Z",
+		ClickURL:  "https://github.com/org/repo/actions/runs/1",
+		EventName: "workflow",
+		Repo:      "org/repo",
+		Details:   []cards.Detail{{Label: "Failed steps", Value: "build"}},
+		Links:     []cards.Link{{Text: "Compare", URL: "https://github.com/org/repo/compare/a...b"}},
+	}
+	rc := &RunContext{
+		Status: "failure",
+		GitHub: map[string]any{
+			"run_id":        "1",
+			"token":         "ghs_secret",
+			"secret_source": "Actions",
+			"event":         map[string]any{"action": "completed", "commits": []any{map[string]any{"id": "abc"}}},
+		},
+		Job: map[string]any{"status": "failure", "unknown_field": "value"},
+	}
+
+	cases := []struct {
+		name     string
+		template string
+		want     map[string]any
+		expErr   string
+	}{
+		{
+			name: "default",
+			want: map[string]any{
+				"title":      "GitHub workflow failure",
+				"subtitle":   "Workflow: ci",
+				"repo":       "org/repo",
+				"ref":        "refs/heads/main",
+				"actor":      "octocat",
+				"timestamp":  "2023-01-02T03:04:05Z",
+				"event_name": "workflow",
+				"status":     "failure",
+				"url":        "https://github.com/org/repo/actions/runs/1",
+				"fields": []any{
+					map[string]any{"label": "Repo", "value": "org/repo"},
+					map[string]any{"label": "Ref", "value": "refs/heads/main"},
+					map[string]any{"label": "Actor", "value": "octocat"},
+					map[string]any{"label": "UTC", "value": "2023-01-02T03:04:05Z"},
+					map[string]any{"label": "Failed steps", "value": "build"},
+				},
+				"links": []any{map[string]any{"text": "Compare", "url": "https://github.com/org/repo/compare/a...b"}},
+			},
+		},
+		{
+			name:     "template",
+			template: `{"summary": {{ json .Title }}, "run": {{ json .GitHub.run_id }}, "severity": {{ if eq .Status "failure" }}"high"{{ else }}"low"{{ end }}}`,
+			want: map[string]any{
+				"summary":  "GitHub workflow failure",
+				"run":      "1",
+				"severity": "high",
+			},
+		},
+		{
+			name:     "context",
+			template: `{"github": {{ json .GitHub }}, "job": {{ json .Job }}}`,
+			want: map[string]any{
+				"github": map[string]any{
+					"run_id": "1",
+					"event":  map[string]any{"action": "completed", "commits": []any{map[string]any{"id": "abc"}}},
+				},
+				"job": map[string]any{"status": "failure"},
+			},
+		},
+		{
+			name:     "invalid_json",
+			template: `{"summary": {{ .Title }}}`,
+			expErr:   "template did not render valid JSON",
+		},
+		{
+			name:     "missing_key",
+			template: `{{ json .GitHub.missing }}`,
+			expErr:   "failed to render template",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			n := &JSON{Run: rc}
+			if tc.template != "" {
+				tmpl, err := NewJSONTemplate(tc.template)
+				if err != nil {
+					t.Fatal(err)
+				}
+				n.Template = tmpl
+			}
+
+			b, err := n.RequestBody(m)
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			var got map[string]any
+			if err := json.Unmarshal(b, &got); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("RequestBody got unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestJSON_Send(t *testing.T) {
+	t.Parallel()
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan *request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests <- &request{header: r.Header, body: b}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+
+	n := &JSON{
+		Header: http.Header{"X-Source": []string{"ci"}},
+		Secret: []byte("secret"),
+	}
+	statusCode, _, err := n.Send(context.Background(), srv.Client(), srv.URL, []byte(`{"title":"hello"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statusCode, http.StatusAccepted; got != want {
+		t.Errorf("status code got %d, want %d", got, want)
+	}
+
+	req := <-requests
+	if got, want := req.header.Get("X-Source"), "ci"; got != want {
+		t.Errorf("X-Source got %q, want %q", got, want)
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(req.body)
+	if got, want := req.header.Get(JSONSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("%s got %q, want %q", JSONSignatureHeader, got, want)
+	}
+}
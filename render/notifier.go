@@ -0,0 +1,249 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render renders cards.Message for the services messages can be sent
+// to, Google Chat, Slack, Microsoft Teams, Discord and any JSON webhook, and
+// sends them to their webhooks.
+package render
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+	"github.com/google-github-actions/send-google-chat-webhook/version"
+)
+
+// Notifier renders messages for a chat service and sends them to its incoming
+// webhooks. Every implementation renders from the same cards.Message.
+type Notifier interface {
+	// Name is the name of the backend, as used in the backend+https:// prefix
+	// of webhook urls.
+	Name() string
+
+	// RequestBody renders the message as the request body of a webhook.
+	RequestBody(m *cards.Message) ([]byte, error)
+
+	// WithIdempotencyKey returns the webhook url u changed so that repeated
+	// sends with the same key are collapsed, if the service supports it.
+	WithIdempotencyKey(u, key string) (string, error)
+
+	// Send posts the request body to the webhook url u. It returns the HTTP
+	// status code, if any, and the created message if the service returns it.
+	Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error)
+}
+
+// defaultNotifiers are the supported backends by name, with their default
+// configuration.
+var defaultNotifiers = map[string]Notifier{
+	GoogleChat{}.Name(): GoogleChat{},
+	Slack{}.Name():      Slack{},
+	Teams{}.Name():      Teams{},
+	Discord{}.Name():    Discord{},
+	(&JSON{}).Name():    &JSON{},
+}
+
+// Notifiers returns the supported backends by name, with the configured
+// backends replacing the default ones of the same name.
+func Notifiers(configured ...Notifier) map[string]Notifier {
+	ns := maps.Clone(defaultNotifiers)
+	for _, n := range configured {
+		ns[n.Name()] = n
+	}
+	return ns
+}
+
+// Names returns the sorted names of the backends.
+func Names() []string {
+	names := make([]string, 0, len(defaultNotifiers))
+	for name := range defaultNotifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseWebhookURL returns the backend of the webhook url among ns and the url
+// to send to. The backend is chosen with a backend+ prefix of the scheme, e.g.
+// slack+https://, and otherwise detected from the host, defaulting to Google
+// Chat.
+func ParseWebhookURL(raw string, ns map[string]Notifier) (Notifier, string, error) {
+	if scheme, rest, ok := strings.Cut(raw, "://"); ok {
+		if name, scheme, ok := strings.Cut(scheme, "+"); ok {
+			n, ok := ns[name]
+			if !ok {
+				return nil, "", fmt.Errorf("unknown backend %q, must be one of %q", name, Names())
+			}
+			return n, scheme + "://" + rest, nil
+		}
+	}
+
+	// The URL holds secrets, so it is not part of the errors.
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse webhook url")
+	}
+	host := strings.ToLower(u.Hostname())
+	name := GoogleChat{}.Name()
+	switch {
+	case host == "hooks.slack.com":
+		name = Slack{}.Name()
+	case strings.HasSuffix(host, ".webhook.office.com") || host == "outlook.office.com" ||
+		strings.HasSuffix(host, ".logic.azure.com"):
+		name = Teams{}.Name()
+	case (host == "discord.com" || host == "discordapp.com") && strings.HasPrefix(u.Path, "/api/webhooks/"):
+		name = Discord{}.Name()
+	}
+	return ns[name], raw, nil
+}
+
+// GoogleChat sends cards to Google Chat webhooks.
+type GoogleChat struct{}
+
+func (GoogleChat) Name() string {
+	return "googlechat"
+}
+
+func (GoogleChat) RequestBody(m *cards.Message) ([]byte, error) {
+	return cards.RequestBody(m)
+}
+
+func (GoogleChat) WithIdempotencyKey(u, key string) (string, error) {
+	return chat.WithIdempotencyKey(u, key)
+}
+
+func (GoogleChat) Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error) {
+	return chat.Send(ctx, client, u, body)
+}
+
+// postJSON posts the request body to the webhook url u of a service other than
+// Google Chat, with the extra headers if any. Any 2xx status code is a success,
+// as services differ.
+func postJSON(ctx context.Context, client *http.Client, u string, body []byte, header http.Header) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating http request failed: %w", chat.RedactURL(err))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", version.UserAgent())
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending http request failed: %w", chat.RedactURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return resp.StatusCode, &chat.HTTPStatusError{StatusCode: resp.StatusCode, Body: b}
+	}
+	return resp.StatusCode, nil
+}
+
+// markupStyle is how a service formats the Chat HTML of the neutral model.
+type markupStyle struct {
+	bold, italic, strike, code string
+	link                       func(url, text string) string
+	// unescape decodes HTML entities, for services not using them.
+	unescape bool
+}
+
+var (
+	markdownStyle = &markupStyle{
+		bold:     "**",
+		italic:   "_",
+		strike:   "~~",
+		code:     "`",
+		link:     func(url, text string) string { return fmt.Sprintf("[%s](%s)", text, url) },
+		unescape: true,
+	}
+	slackStyle = &markupStyle{
+		bold:   "*",
+		italic: "_",
+		strike: "~",
+		code:   "`",
+		link:   func(url, text string) string { return fmt.Sprintf("<%s|%s>", url, text) },
+	}
+	plainStyle = &markupStyle{
+		link:     func(url, text string) string { return text },
+		unescape: true,
+	}
+)
+
+var (
+	// MentionRe matches Chat user mentions, e.g. <users/all>.
+	MentionRe        = regexp.MustCompile(`<users/[^>]*>`)
+	chatHTMLBreakRe  = regexp.MustCompile(`(?i)<br\s*/?>`)
+	chatHTMLBoldRe   = regexp.MustCompile(`(?is)<b>(.*?)</b>`)
+	chatHTMLItalicRe = regexp.MustCompile(`(?is)<i>(.*?)</i>`)
+	chatHTMLStrikeRe = regexp.MustCompile(`(?is)<s>(.*?)</s>`)
+	chatHTMLFontRe   = regexp.MustCompile(`(?is)<font[^>]*>(.*?)</font>`)
+	chatHTMLLinkRe   = regexp.MustCompile(`(?is)<a href="([^"]*)">(.*?)</a>`)
+)
+
+// format converts the subset of HTML supported by Chat cards, as used in the
+// neutral model, e.g. by markdownToChat, to the markup of the style. Chat user
+// mentions, e.g. <users/all>, mean nothing to other services and are removed.
+func (st *markupStyle) format(s string) string {
+	s = MentionRe.ReplaceAllString(s, "")
+	s = chatHTMLBreakRe.ReplaceAllString(s, "\n")
+	s = chatHTMLLinkRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := chatHTMLLinkRe.FindStringSubmatch(m)
+		return st.link(html.UnescapeString(sub[1]), sub[2])
+	})
+	wrap := func(re *regexp.Regexp, w string) {
+		s = re.ReplaceAllStringFunc(s, func(m string) string {
+			// Markers around surrounding spaces are not rendered.
+			inner := re.FindStringSubmatch(m)[1]
+			trimmed := strings.TrimSpace(inner)
+			if trimmed == "" || w == "" {
+				return inner
+			}
+			return strings.Replace(inner, trimmed, w+trimmed+w, 1)
+		})
+	}
+	wrap(chatHTMLBoldRe, st.bold)
+	wrap(chatHTMLItalicRe, st.italic)
+	wrap(chatHTMLStrikeRe, st.strike)
+	wrap(chatHTMLFontRe, st.code)
+
+	if st.unescape {
+		return html.UnescapeString(s)
+	}
+	// Slack only uses &amp;, &lt; and &gt;.
+	return strings.NewReplacer("&#34;", `"`, "&#39;", "'", "&quot;", `"`).Replace(s)
+}
+
+// truncate shortens s to at most n runes, for services limiting the length of
+// fields.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
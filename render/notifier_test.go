@@ -0,0 +1,355 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abcxyz/pkg/testutil"
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google/go-cmp/cmp"
+)
+
+// testNotifierMessage is a message using every part of cards.Message.
+var testNotifierMessage = &cards.Message{
+	Title:         "GitHub workflow failed",
+	Subtitle:      "<b>ci</b> on main",
+	Ref:           "refs/heads/main",
+	Actor:         "octocat",
+	Timestamp:     "2023-01-02 03:04:05",
+	ClickURL:      "https://github.com/org/repo/actions/runs/1",
+	HeaderIconURL: cards.FailureHeaderIconURL,
+	EventName:     "workflow",
+	Repo:          "org/repo",
+	Text:          "<users/all> see <a href=\"https://example.com\">logs</a>",
+	Details: []cards.Detail{
+		{Label: "Failed steps", Value: "build, test"},
+		{Label: "PR", Value: "#1", ButtonText: "Review", ButtonURL: "https://github.com/org/repo/pull/1"},
+	},
+	Chips: []string{"bug"},
+	Grid: &cards.Grid{
+		Title: "Matrix",
+		Items: []cards.Detail{{Label: "os", Value: "linux"}},
+	},
+	Excerpt: "it &amp; <i>broke</i>",
+	Links:   []cards.Link{{Text: "Compare", URL: "https://github.com/org/repo/compare/a...b"}},
+}
+
+func TestParseWebhookURL(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		url         string
+		wantBackend string
+		wantURL     string
+		expErr      string
+	}{
+		{
+			name:        "google_chat",
+			url:         "https://chat.googleapis.com/v1/spaces/AAAA/messages?key=k&token=t",
+			wantBackend: "googlechat",
+			wantURL:     "https://chat.googleapis.com/v1/spaces/AAAA/messages?key=k&token=t",
+		},
+		{
+			name:        "slack",
+			url:         "https://hooks.slack.com/services/T0/B0/X",
+			wantBackend: "slack",
+			wantURL:     "https://hooks.slack.com/services/T0/B0/X",
+		},
+		{
+			name:        "teams",
+			url:         "https://contoso.webhook.office.com/webhookb2/abc",
+			wantBackend: "teams",
+			wantURL:     "https://contoso.webhook.office.com/webhookb2/abc",
+		},
+		{
+			name:        "teams_workflow",
+			url:         "https://prod-00.westus.logic.azure.com:443/workflows/abc/triggers/manual/paths/invoke",
+			wantBackend: "teams",
+			wantURL:     "https://prod-00.westus.logic.azure.com:443/workflows/abc/triggers/manual/paths/invoke",
+		},
+		{
+			name:        "discord",
+			url:         "https://discord.com/api/webhooks/1/abc",
+			wantBackend: "discord",
+			wantURL:     "https://discord.com/api/webhooks/1/abc",
+		},
+		{
+			name:        "prefix",
+			url:         "slack+https://chat.example.com/hook",
+			wantBackend: "slack",
+			wantURL:     "https://chat.example.com/hook",
+		},
+		{
+			name:   "unknown_prefix",
+			url:    "irc+https://example.com/hook",
+			expErr: `unknown backend "irc"`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			n, u, err := ParseWebhookURL(tc.url, Notifiers())
+			if diff := testutil.DiffErrString(err, tc.expErr); diff != "" {
+				t.Fatal(diff)
+			}
+			if err != nil {
+				return
+			}
+			if got, want := n.Name(), tc.wantBackend; got != want {
+				t.Errorf("backend got %q, want %q", got, want)
+			}
+			if got, want := u, tc.wantURL; got != want {
+				t.Errorf("url got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestMarkupStyleFormat(t *testing.T) {
+	t.Parallel()
+
+	in := `<users/all> <b>bold</b> <i>it </i><s>gone</s><br><a href="https://x.test/?a=1&amp;b=2">link</a> <font color="#6a737d">code</font> a &lt; b &#34;q&#34;`
+
+	cases := []struct {
+		name  string
+		style *markupStyle
+		want  string
+	}{
+		{
+			name:  "markdown",
+			style: markdownStyle,
+			want:  " **bold** _it_ ~~gone~~\n[link](https://x.test/?a=1&b=2) `code` a < b \"q\"",
+		},
+		{
+			name:  "slack",
+			style: slackStyle,
+			want:  " *bold* _it_ ~gone~\n<https://x.test/?a=1&b=2|link> `code` a &lt; b \"q\"",
+		},
+		{
+			name:  "plain",
+			style: plainStyle,
+			want:  " bold it gone\nlink code a < b \"q\"",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, tc.style.format(in)); diff != "" {
+				t.Errorf("format got unexpected result (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDiscord_RequestBody(t *testing.T) {
+	t.Parallel()
+
+	b, err := Discord{}.RequestBody(testNotifierMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]any{
+		"content": "see [logs](https://example.com)",
+		"embeds": []any{
+			map[string]any{
+				"title":       "GitHub workflow failed",
+				"url":         "https://github.com/org/repo/actions/runs/1",
+				"description": "**ci** on main\n\n> it & _broke_\n\n`bug`\n\n[Compare](https://github.com/org/repo/compare/a...b)",
+				"color":       float64(0xcb2431),
+				"thumbnail":   map[string]any{"url": cards.FailureHeaderIconURL},
+				"fields": []any{
+					map[string]any{"name": "Repo", "value": "org/repo", "inline": true},
+					map[string]any{"name": "Ref", "value": "refs/heads/main", "inline": true},
+					map[string]any{"name": "Actor", "value": "octocat", "inline": true},
+					map[string]any{"name": "UTC", "value": "2023-01-02 03:04:05", "inline": true},
+					map[string]any{"name": "Failed steps", "value": "build, test", "inline": true},
+					map[string]any{"name": "PR", "value": "#1 [Review](https://github.com/org/repo/pull/1)", "inline": true},
+					map[string]any{"name": "os", "value": "linux", "inline": true},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("RequestBody got unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestSlack_RequestBody(t *testing.T) {
+	t.Parallel()
+
+	b, err := Slack{}.RequestBody(testNotifierMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Text   string           `json:"text"`
+		Blocks []map[string]any `json:"blocks"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := got.Text, "GitHub workflow failed"; got != want {
+		t.Errorf("text got %q, want %q", got, want)
+	}
+	var types []string
+	for _, b := range got.Blocks {
+		types = append(types, b["type"].(string))
+	}
+	wantTypes := []string{"header", "context", "section", "section", "context", "section", "section", "section", "actions"}
+	if diff := cmp.Diff(wantTypes, types); diff != "" {
+		t.Errorf("block types got unexpected result (-want +got):\n%s", diff)
+	}
+
+	wantFields := []any{
+		map[string]any{"type": "mrkdwn", "text": "*Repo*\norg/repo"},
+		map[string]any{"type": "mrkdwn", "text": "*Ref*\nrefs/heads/main"},
+		map[string]any{"type": "mrkdwn", "text": "*Actor*\noctocat"},
+		map[string]any{"type": "mrkdwn", "text": "*UTC*\n2023-01-02 03:04:05"},
+		map[string]any{"type": "mrkdwn", "text": "*Failed steps*\nbuild, test"},
+		map[string]any{"type": "mrkdwn", "text": "*PR*\n#1 <https://github.com/org/repo/pull/1|Review>"},
+	}
+	if diff := cmp.Diff(wantFields, got.Blocks[3]["fields"]); diff != "" {
+		t.Errorf("fields got unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestSlack_RequestBodyLongField(t *testing.T) {
+	t.Parallel()
+
+	m := &cards.Message{
+		Title:   "GitHub workflow failed",
+		Details: []cards.Detail{{Label: "Failed steps", Value: strings.Repeat("a", 2500)}},
+	}
+	b, err := Slack{}.RequestBody(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Blocks []struct {
+			Fields []struct {
+				Text string `json:"text"`
+			} `json:"fields"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	var fields int
+	for _, b := range got.Blocks {
+		for _, f := range b.Fields {
+			fields++
+			if n := len([]rune(f.Text)); n > slackMaxFieldLength {
+				t.Errorf("field %q got %d characters, want at most %d", f.Text[:20], n, slackMaxFieldLength)
+			}
+		}
+	}
+	if fields == 0 {
+		t.Errorf("got no fields")
+	}
+}
+
+func TestTeams_RequestBody(t *testing.T) {
+	t.Parallel()
+
+	b, err := Teams{}.RequestBody(testNotifierMessage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string `json:"contentType"`
+			Content     struct {
+				Type    string           `json:"type"`
+				Body    []map[string]any `json:"body"`
+				Actions []map[string]any `json:"actions"`
+			} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := got.Type, "message"; got != want {
+		t.Errorf("type got %q, want %q", got, want)
+	}
+	if got, want := len(got.Attachments), 1; got != want {
+		t.Fatalf("got %d attachments, want %d", got, want)
+	}
+	card := got.Attachments[0].Content
+	if got, want := card.Type, "AdaptiveCard"; got != want {
+		t.Errorf("card type got %q, want %q", got, want)
+	}
+
+	var types []string
+	for _, b := range card.Body {
+		types = append(types, b["type"].(string))
+	}
+	wantTypes := []string{"ColumnSet", "TextBlock", "FactSet", "TextBlock", "TextBlock", "FactSet", "TextBlock"}
+	if diff := cmp.Diff(wantTypes, types); diff != "" {
+		t.Errorf("body types got unexpected result (-want +got):\n%s", diff)
+	}
+
+	wantActions := []map[string]any{
+		{"type": "Action.OpenUrl", "title": "Open workflow", "url": "https://github.com/org/repo/actions/runs/1"},
+		{"type": "Action.OpenUrl", "title": "Compare", "url": "https://github.com/org/repo/compare/a...b"},
+	}
+	if diff := cmp.Diff(wantActions, card.Actions); diff != "" {
+		t.Errorf("actions got unexpected result (-want +got):\n%s", diff)
+	}
+}
+
+func TestNotifier_SendRedactsURL(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u := srv.URL + "/services/test-secret-path?token=test-token"
+	client := srv.Client()
+	srv.Close()
+
+	for _, n := range []Notifier{Slack{}, Teams{}, Discord{}} {
+		_, _, err := n.Send(context.Background(), client, u, []byte(`{}`))
+		if err == nil {
+			t.Fatalf("%s: Send() got no error for a closed server", n.Name())
+		}
+		for _, secret := range []string{"test-secret-path", "test-token"} {
+			if strings.Contains(err.Error(), secret) {
+				t.Errorf("%s: Send() error %q contains %q of the webhook url", n.Name(), err, secret)
+			}
+		}
+
+		if _, _, err := n.Send(context.Background(), client, "https://example.com/\x7f?token=test-token", []byte(`{}`)); err == nil || strings.Contains(err.Error(), "test-token") {
+			t.Errorf("%s: Send() got error %v for an invalid url, want one without its token", n.Name(), err)
+		}
+	}
+}
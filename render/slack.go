@@ -0,0 +1,162 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+// Limits of Slack Block Kit.
+const (
+	slackMaxHeaderLength  = 150
+	slackMaxTextLength    = 3000
+	slackMaxFieldLength   = 2000
+	slackMaxSectionFields = 10
+	slackMaxButtons       = 25
+)
+
+// Slack sends Block Kit messages to Slack incoming webhooks.
+type Slack struct{}
+
+func (Slack) Name() string {
+	return "slack"
+}
+
+func (Slack) RequestBody(m *cards.Message) ([]byte, error) {
+	mrkdwn := func(s string) map[string]any {
+		return map[string]any{
+			"type": "mrkdwn",
+			"text": truncate(slackStyle.format(s), slackMaxTextLength),
+		}
+	}
+	// Fields of sections are limited to less text than other text objects.
+	field := func(s string) map[string]any {
+		return map[string]any{
+			"type": "mrkdwn",
+			"text": truncate(slackStyle.format(s), slackMaxFieldLength),
+		}
+	}
+	fieldSections := func(fields []cards.Detail) []any {
+		var sections []any
+		for i := 0; i < len(fields); i += slackMaxSectionFields {
+			var texts []any
+			for _, f := range fields[i:min(i+slackMaxSectionFields, len(fields))] {
+				value := f.Value
+				if f.ButtonURL != "" {
+					value = fmt.Sprintf(`%s <a href="%s">%s</a>`, value, f.ButtonURL, f.ButtonText)
+				}
+				texts = append(texts, field(fmt.Sprintf("<b>%s</b><br>%s", f.Label, value)))
+			}
+			sections = append(sections, map[string]any{
+				"type":   "section",
+				"fields": texts,
+			})
+		}
+		return sections
+	}
+
+	blocks := []any{
+		map[string]any{
+			"type": "header",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": truncate(plainStyle.format(m.Title), slackMaxHeaderLength),
+			},
+		},
+	}
+	if m.Subtitle != "" {
+		blocks = append(blocks, map[string]any{
+			"type":     "context",
+			"elements": []any{mrkdwn(m.Subtitle)},
+		})
+	}
+	if text := strings.TrimSpace(slackStyle.format(m.Text)); text != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": mrkdwn(text),
+		})
+	}
+	blocks = append(blocks, fieldSections(cards.Fields(m))...)
+	if len(m.Chips) > 0 {
+		chips := make([]string, 0, len(m.Chips))
+		for _, c := range m.Chips {
+			chips = append(chips, "<font>"+c+"</font>")
+		}
+		blocks = append(blocks, map[string]any{
+			"type":     "context",
+			"elements": []any{mrkdwn(strings.Join(chips, " "))},
+		})
+	}
+	if m.Grid != nil {
+		if m.Grid.Title != "" {
+			blocks = append(blocks, map[string]any{
+				"type": "section",
+				"text": mrkdwn("<b>" + m.Grid.Title + "</b>"),
+			})
+		}
+		blocks = append(blocks, fieldSections(m.Grid.Items)...)
+	}
+	if m.Excerpt != "" {
+		blocks = append(blocks, map[string]any{
+			"type": "section",
+			"text": mrkdwn(m.Excerpt),
+		})
+	}
+
+	links := append([]cards.Link{{Text: fmt.Sprintf("Open %s", m.EventName), URL: m.ClickURL}}, m.Links...)
+	buttons := make([]any, 0, len(links))
+	for _, l := range links[:min(len(links), slackMaxButtons)] {
+		buttons = append(buttons, map[string]any{
+			"type": "button",
+			"text": map[string]any{
+				"type": "plain_text",
+				"text": l.Text,
+			},
+			"url": l.URL,
+		})
+	}
+	blocks = append(blocks, map[string]any{
+		"type":     "actions",
+		"elements": buttons,
+	})
+
+	res, err := json.Marshal(map[string]any{
+		// The text is shown in notifications.
+		"text":   plainStyle.format(m.Title),
+		"blocks": blocks,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshal jsonData: %w", err)
+	}
+	return res, nil
+}
+
+// WithIdempotencyKey returns u unchanged, Slack webhooks can't deduplicate
+// messages.
+func (Slack) WithIdempotencyKey(u, key string) (string, error) {
+	return u, nil
+}
+
+func (Slack) Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error) {
+	statusCode, err := postJSON(ctx, client, u, body, nil)
+	return statusCode, nil, err
+}
@@ -0,0 +1,171 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google-github-actions/send-google-chat-webhook/cards"
+	"github.com/google-github-actions/send-google-chat-webhook/chat"
+)
+
+// Teams sends Adaptive Cards to Microsoft Teams incoming webhooks and
+// workflows.
+type Teams struct{}
+
+func (Teams) Name() string {
+	return "teams"
+}
+
+func (Teams) RequestBody(m *cards.Message) ([]byte, error) {
+	textBlock := func(s string) map[string]any {
+		return map[string]any{
+			"type": "TextBlock",
+			"text": markdownStyle.format(s),
+			"wrap": true,
+		}
+	}
+	factSet := func(fields []cards.Detail) map[string]any {
+		facts := make([]any, 0, len(fields))
+		for _, f := range fields {
+			value := f.Value
+			if f.ButtonURL != "" {
+				value = fmt.Sprintf(`%s <a href="%s">%s</a>`, value, f.ButtonURL, f.ButtonText)
+			}
+			facts = append(facts, map[string]any{
+				"title": plainStyle.format(f.Label),
+				"value": markdownStyle.format(value),
+			})
+		}
+		return map[string]any{
+			"type":  "FactSet",
+			"facts": facts,
+		}
+	}
+
+	header := []any{
+		map[string]any{
+			"type":   "TextBlock",
+			"text":   plainStyle.format(m.Title),
+			"weight": "Bolder",
+			"size":   "Medium",
+			"wrap":   true,
+		},
+	}
+	if m.Subtitle != "" {
+		subtitle := textBlock(m.Subtitle)
+		subtitle["isSubtle"] = true
+		subtitle["spacing"] = "None"
+		header = append(header, subtitle)
+	}
+	columns := []any{
+		map[string]any{
+			"type":  "Column",
+			"width": "stretch",
+			"items": header,
+		},
+	}
+	if m.HeaderIconURL != "" {
+		columns = append([]any{
+			map[string]any{
+				"type":  "Column",
+				"width": "auto",
+				"items": []any{
+					map[string]any{
+						"type": "Image",
+						"url":  m.HeaderIconURL,
+						"size": "Small",
+					},
+				},
+			},
+		}, columns...)
+	}
+
+	body := []any{
+		map[string]any{
+			"type":    "ColumnSet",
+			"columns": columns,
+		},
+	}
+	if text := strings.TrimSpace(markdownStyle.format(m.Text)); text != "" {
+		body = append(body, textBlock(text))
+	}
+	body = append(body, factSet(cards.Fields(m)))
+	if len(m.Chips) > 0 {
+		chips := make([]string, 0, len(m.Chips))
+		for _, c := range m.Chips {
+			chips = append(chips, "<font>"+c+"</font>")
+		}
+		body = append(body, textBlock(strings.Join(chips, " ")))
+	}
+	if m.Grid != nil {
+		if m.Grid.Title != "" {
+			title := textBlock(m.Grid.Title)
+			title["weight"] = "Bolder"
+			body = append(body, title)
+		}
+		body = append(body, factSet(m.Grid.Items))
+	}
+	if m.Excerpt != "" {
+		excerpt := textBlock(m.Excerpt)
+		excerpt["isSubtle"] = true
+		body = append(body, excerpt)
+	}
+
+	links := append([]cards.Link{{Text: fmt.Sprintf("Open %s", m.EventName), URL: m.ClickURL}}, m.Links...)
+	actions := make([]any, 0, len(links))
+	for _, l := range links {
+		actions = append(actions, map[string]any{
+			"type":  "Action.OpenUrl",
+			"title": l.Text,
+			"url":   l.URL,
+		})
+	}
+
+	res, err := json.Marshal(map[string]any{
+		"type": "message",
+		"attachments": []any{
+			map[string]any{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body":    body,
+					"actions": actions,
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshal jsonData: %w", err)
+	}
+	return res, nil
+}
+
+// WithIdempotencyKey returns u unchanged, Teams webhooks can't deduplicate
+// messages.
+func (Teams) WithIdempotencyKey(u, key string) (string, error) {
+	return u, nil
+}
+
+func (Teams) Send(ctx context.Context, client *http.Client, u string, body []byte) (int, *chat.Message, error) {
+	statusCode, err := postJSON(ctx, client, u, body, nil)
+	return statusCode, nil, err
+}
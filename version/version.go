@@ -0,0 +1,50 @@
+// Copyright 2023 The Authors (see AUTHORS file)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version is the version of the binary, set at build time with -ldflags
+// by goreleaser.
+package version
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Name, Version and Commit are overridden at build time, e.g.
+// -X=github.com/google-github-actions/send-google-chat-webhook/version.Version=1.2.3.
+var (
+	// Name is the name of the binary.
+	Name = "send-google-chat-webhook"
+
+	// Version is the release version, without the v prefix.
+	Version = "source"
+
+	// Commit is the git commit the binary was built from.
+	Commit = "HEAD"
+)
+
+// OSArch is the operating system and architecture the binary was built for.
+var OSArch = runtime.GOOS + "/" + runtime.GOARCH
+
+// HumanVersion returns the version as printed by the version command, e.g.
+// "send-google-chat-webhook 1.2.3 (abc1234, linux/amd64)".
+func HumanVersion() string {
+	return fmt.Sprintf("%s %s (%s, %s)", Name, Version, Commit, OSArch)
+}
+
+// UserAgent returns the User-Agent header of HTTP requests, e.g.
+// "send-google-chat-webhook/1.2.3 (+https://github.com/google-github-actions/send-google-chat-webhook)".
+func UserAgent() string {
+	return fmt.Sprintf("%s/%s (+https://github.com/google-github-actions/send-google-chat-webhook)", Name, Version)
+}